@@ -0,0 +1,277 @@
+package gddoexp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// GithubAuth stores the credentials used to send requests to Github API.
+// Authenticated requests have a more flexible rate limit. Only one kind of
+// credential should be informed.
+type GithubAuth struct {
+	// ID and Secret are the OAuth application credentials, sent as query
+	// parameters.
+	ID     string
+	Secret string
+
+	// Token is a personal access token, sent in the Authorization HTTP header.
+	Token string
+
+	// App identifies a Github App installation. The installation tokens are
+	// created and renewed automatically.
+	App *GithubApp
+}
+
+// GithubApp stores the information necessary to create installation tokens
+// for a Github App.
+type GithubApp struct {
+	// ID is the Github App identifier.
+	ID int64
+
+	// InstallationID identifies the installation of the Github App in an
+	// account or organization.
+	InstallationID int64
+
+	// PrivateKey is the PEM encoded RSA private key generated for the Github
+	// App.
+	PrivateKey []byte
+}
+
+// Validate checks if one, and only one, kind of credential was informed.
+func (a GithubAuth) Validate() error {
+	var kinds int
+
+	if a.ID != "" || a.Secret != "" {
+		if a.ID == "" || a.Secret == "" {
+			return fmt.Errorf("Github client ID and secret must be informed together")
+		}
+		kinds++
+	}
+
+	if a.Token != "" {
+		kinds++
+	}
+
+	if a.App != nil {
+		if a.App.ID == 0 || a.App.InstallationID == 0 {
+			return fmt.Errorf("Github App ID and installation ID must be informed")
+		}
+
+		if _, err := parsePrivateKey(a.App.PrivateKey); err != nil {
+			return err
+		}
+		kinds++
+	}
+
+	if kinds == 0 {
+		return fmt.Errorf("no Github credential informed")
+	} else if kinds > 1 {
+		return fmt.Errorf("only one kind of Github credential can be informed")
+	}
+
+	return nil
+}
+
+// transport wraps the given transport, adding the credentials to the
+// requests. The base URL is used to create Github App installation tokens.
+func (a GithubAuth) transport(transport http.RoundTripper, baseURL *url.URL) http.RoundTripper {
+	switch {
+	case a.Token != "":
+		return &tokenTransport{
+			token: func() (string, error) {
+				return a.Token, nil
+			},
+			transport: transport,
+		}
+
+	case a.App != nil:
+		// the key was already checked when validating the credentials
+		key, _ := parsePrivateKey(a.App.PrivateKey)
+		installation := &installationToken{
+			app:       *a.App,
+			key:       key,
+			baseURL:   baseURL,
+			transport: transport,
+		}
+
+		return &tokenTransport{
+			token:     installation.get,
+			transport: transport,
+		}
+	}
+
+	return &github.UnauthenticatedRateLimitedTransport{
+		ClientID:     a.ID,
+		ClientSecret: a.Secret,
+		Transport:    transport,
+	}
+}
+
+// tokenTransport adds a token in the Authorization HTTP header of each
+// request.
+type tokenTransport struct {
+	token     func() (string, error)
+	transport http.RoundTripper
+}
+
+// RoundTrip sends the request with the token. The original request is not
+// changed.
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.token()
+	if err != nil {
+		return nil, err
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = append([]string(nil), v...)
+	}
+	r2.Header.Set("Authorization", "token "+token)
+
+	return t.transport.RoundTrip(r2)
+}
+
+// installationToken creates Github App installation tokens, reusing them
+// until they are close to expire.
+type installationToken struct {
+	app       GithubApp
+	key       *rsa.PrivateKey
+	baseURL   *url.URL
+	transport http.RoundTripper
+
+	mutex     sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// get returns a valid installation token, creating a new one when necessary.
+func (i *installationToken) get() (string, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// renew the token a little before it expires, so it doesn't expire in the
+	// middle of a request
+	if i.token != "" && time.Now().Add(time.Minute).Before(i.expiresAt) {
+		return i.token, nil
+	}
+
+	jwt, err := i.jwt()
+	if err != nil {
+		return "", err
+	}
+
+	endpoint, err := i.baseURL.Parse(fmt.Sprintf("app/installations/%d/access_tokens", i.app.InstallationID))
+	if err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequest("POST", endpoint.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	r.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	response, err := i.transport.RoundTrip(r)
+	if err != nil {
+		return "", fmt.Errorf("error creating Github App installation token: %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("error creating Github App installation token: unexpected status code %d", response.StatusCode)
+	}
+
+	var installation struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&installation); err != nil {
+		return "", fmt.Errorf("error decoding Github App installation token: %s", err)
+	}
+
+	i.token = installation.Token
+	i.expiresAt = installation.ExpiresAt
+	return i.token, nil
+}
+
+// jwt builds the JSON Web Token that identifies the Github App, signed with
+// the App private key (RS256).
+func (i *installationToken) jwt() (string, error) {
+	now := time.Now()
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]int64{
+		// the issued time is in the past to allow some clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": i.app.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buffer.WriteString(".")
+	buffer.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	hash := sha256.Sum256(buffer.Bytes())
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing Github App token: %s", err)
+	}
+
+	buffer.WriteString(".")
+	buffer.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+	return buffer.String(), nil
+}
+
+// parsePrivateKey decodes a PEM encoded RSA private key, in PKCS#1 (format
+// generated by Github) or PKCS#8.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Github App private key isn't PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Github App private key: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Github App private key isn't a RSA key")
+	}
+
+	return rsaKey, nil
+}
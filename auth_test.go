@@ -0,0 +1,163 @@
+package gddoexp_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestGithubAuthValidate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("error generating private key: %s", err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	data := []struct {
+		description   string
+		auth          gddoexp.GithubAuth
+		expectedError bool
+	}{
+		{
+			description: "it should accept client credentials",
+			auth:        gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123"},
+		},
+		{
+			description: "it should accept a personal access token",
+			auth:        gddoexp.GithubAuth{Token: "abc123"},
+		},
+		{
+			description: "it should accept a Github App",
+			auth: gddoexp.GithubAuth{
+				App: &gddoexp.GithubApp{ID: 1, InstallationID: 2, PrivateKey: privateKey},
+			},
+		},
+		{
+			description:   "it should refuse an incomplete client credential",
+			auth:          gddoexp.GithubAuth{ID: "exampleuser"},
+			expectedError: true,
+		},
+		{
+			description:   "it should refuse more than one kind of credential",
+			auth:          gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123", Token: "abc123"},
+			expectedError: true,
+		},
+		{
+			description:   "it should refuse empty credentials",
+			expectedError: true,
+		},
+		{
+			description: "it should refuse a Github App with an invalid private key",
+			auth: gddoexp.GithubAuth{
+				App: &gddoexp.GithubApp{ID: 1, InstallationID: 2, PrivateKey: []byte("abc123")},
+			},
+			expectedError: true,
+		},
+	}
+
+	for i, item := range data {
+		err := item.auth.Validate()
+
+		if item.expectedError && err == nil {
+			t.Errorf("[%d] %s: expected an error", i, item.description)
+		} else if !item.expectedError && err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+		}
+	}
+}
+
+func TestGithubAuthTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("error generating private key: %s", err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	data := []struct {
+		description   string
+		auth          gddoexp.GithubAuth
+		expectedToken string
+	}{
+		{
+			description:   "it should send the personal access token",
+			auth:          gddoexp.GithubAuth{Token: "abc123"},
+			expectedToken: "abc123",
+		},
+		{
+			description: "it should send the Github App installation token",
+			auth: gddoexp.GithubAuth{
+				App: &gddoexp.GithubApp{ID: 1, InstallationID: 2, PrivateKey: privateKey},
+			},
+			expectedToken: "installation123",
+		},
+	}
+
+	for i, item := range data {
+		var installationTokens int
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/app/installations/2/access_tokens":
+				if r.Method != "POST" || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				installationTokens++
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "installation123", "expires_at": "%s"}`,
+					time.Now().Add(time.Hour).Format(time.RFC3339))
+
+			case "/repos/rafaeljusto/dns":
+				if r.Header.Get("Authorization") != "token "+item.expectedToken {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				fmt.Fprint(w, `{"fork": false}`)
+
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+
+		checker, err := gddoexp.NewChecker(gddoexp.Config{
+			BaseURL: server.URL,
+			Auth:    &item.auth,
+		})
+		if err != nil {
+			t.Fatalf("[%d] %s: error building checker: %s", i, item.description, err)
+		}
+
+		for j := 0; j < 2; j++ {
+			if _, _, err := checker.IsFastForkPackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/dns"}); err != nil {
+				t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			}
+		}
+
+		if item.auth.App != nil && installationTokens != 1 {
+			t.Errorf("[%d] %s: expected the installation token to be reused, but %d were created", i, item.description, installationTokens)
+		}
+
+		server.Close()
+	}
+}
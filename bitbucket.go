@@ -0,0 +1,132 @@
+package gddoexp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// defaultBitbucketURL is the public Bitbucket API endpoint.
+const defaultBitbucketURL = "https://api.bitbucket.org/2.0/"
+
+// bitbucketProvider retrieves the repository information from Bitbucket API.
+type bitbucketProvider struct {
+	checker *Checker
+	baseURL *url.URL
+}
+
+// Match checks if the path has the "bitbucket.org/owner/repo" layout.
+func (bitbucketProvider) Match(path string) bool {
+	return matchRepository(path)
+}
+
+// bitbucketErrorCodes identifies the errors from Bitbucket API.
+var bitbucketErrorCodes = hostErrorCodes{
+	fetch:      ErrorCodeBitbucketFetch,
+	notFound:   ErrorCodeBitbucketNotFound,
+	statusCode: ErrorCodeBitbucketStatusCode,
+	parse:      ErrorCodeBitbucketParse,
+}
+
+// bitbucketRepository is the repository information returned by Bitbucket
+// API. A fork has the parent repository.
+type bitbucketRepository struct {
+	FullName  string    `json:"full_name"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+	Parent    *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+}
+
+// bitbucketCommits is a page of commits returned by Bitbucket API, sorted
+// from the newest to the oldest commit. Next is the URL of the following page.
+type bitbucketCommits struct {
+	Values []struct {
+		Hash string    `json:"hash"`
+		Date time.Time `json:"date"`
+	} `json:"values"`
+	Next string `json:"next"`
+}
+
+// Repository retrieves the repository information from Bitbucket. This
+// function also returns if the response was retrieved from a local cache.
+func (p bitbucketProvider) Repository(ctx context.Context, path string) (*Repository, bool, error) {
+	owner, repo := parse(path)
+	endpoint := p.baseURL.ResolveReference(&url.URL{
+		Path: fmt.Sprintf("repositories/%s/%s", owner, repo),
+	})
+
+	var repository bitbucketRepository
+	_, cache, err := p.checker.getJSON(ctx, path, endpoint.String(), bitbucketErrorCodes, &repository)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &Repository{
+		FullName:  repository.FullName,
+		Fork:      repository.Parent != nil,
+		CreatedAt: repository.CreatedOn,
+		UpdatedAt: repository.UpdatedOn,
+	}
+
+	if repository.Parent != nil {
+		result.Parent = repository.Parent.FullName
+	}
+
+	return result, cache, nil
+}
+
+// CommitsSince will retrieve the commits from a Bitbucket repository made
+// after the since date that touched the package directory. As Bitbucket API
+// can't filter the commits by date, and it lists them in topological order, so
+// an old commit of a merged branch can come before recent ones, all commits of
+// each page are filtered. The pages are retrieved until a page without recent
+// commits is found, up to maxCommitPages pages. This function also returns if
+// all responses were retrieved from a local cache.
+func (p bitbucketProvider) CommitsSince(ctx context.Context, path string, since time.Time) ([]Commit, bool, error) {
+	owner, repo := parse(path)
+	commitsURL := p.baseURL.ResolveReference(&url.URL{
+		Path: fmt.Sprintf("repositories/%s/%s/commits", owner, repo),
+	})
+
+	// the next pages keep the path filter
+	if dir := packageDir(path); dir != "" {
+		commitsURL.RawQuery = url.Values{"path": {dir}}.Encode()
+	}
+	endpoint := commitsURL.String()
+
+	var commits []Commit
+	cache := true
+
+	for pages := 0; endpoint != "" && pages < maxCommitPages; pages++ {
+		var page bitbucketCommits
+		_, pageCache, err := p.checker.getJSON(ctx, path, endpoint, bitbucketErrorCodes, &page)
+		if err != nil {
+			return nil, false, err
+		}
+		cache = cache && pageCache
+
+		recent := false
+		for _, commit := range page.Values {
+			if commit.Date.Before(since) {
+				continue
+			}
+
+			recent = true
+			commits = append(commits, Commit{
+				SHA:  commit.Hash,
+				Date: commit.Date,
+			})
+		}
+
+		if !recent {
+			break
+		}
+
+		endpoint = page.Next
+	}
+
+	return commits, cache, nil
+}
@@ -0,0 +1,162 @@
+package gddoexp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestBitbucket(t *testing.T) {
+	forkDate := time.Now().Add(-30 * 24 * time.Hour)
+
+	var activePages int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/rafaeljusto/unused":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/unused", "created_on": "%s", "updated_on": "%s"}`,
+				time.Now().Add(-5*365*24*time.Hour).Format(time.RFC3339),
+				time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339))
+
+		case "/repositories/rafaeljusto/fork":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/fork", "created_on": "%s", "updated_on": "%s", "parent": {"full_name": "golang/fork"}}`,
+				forkDate.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/repositories/golang/fork":
+			fmt.Fprint(w, `{"full_name": "golang/fork"}`)
+
+		case "/repositories/rafaeljusto/fork/commits":
+			// the commits are split in two pages, and the second page has a
+			// commit older than the analyzed period
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `{"values": [{"hash": "c2", "date": "%s"}, {"hash": "c1", "date": "%s"}]}`,
+					forkDate.Add(time.Hour).Format(time.RFC3339),
+					time.Now().Add(-10*365*24*time.Hour).Format(time.RFC3339))
+				return
+			}
+
+			fmt.Fprintf(w, `{"values": [{"hash": "c3", "date": "%s"}], "next": "%s/repositories/rafaeljusto/fork/commits?page=2"}`,
+				forkDate.Add(2*time.Hour).Format(time.RFC3339), server.URL)
+
+		case "/repositories/rafaeljusto/merged":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/merged", "created_on": "%s", "updated_on": "%s", "parent": {"full_name": "golang/fork"}}`,
+				forkDate.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/repositories/rafaeljusto/merged/commits":
+			// the commits are in topological order, so an old commit of a
+			// merged branch comes before a recent commit
+			fmt.Fprintf(w, `{"values": [{"hash": "c3", "date": "%s"}, {"hash": "c1", "date": "%s"}, {"hash": "c4", "date": "%s"}]}`,
+				forkDate.Add(time.Hour).Format(time.RFC3339),
+				time.Now().Add(-10*365*24*time.Hour).Format(time.RFC3339),
+				forkDate.Add(10*24*time.Hour).Format(time.RFC3339))
+
+		case "/repositories/rafaeljusto/active":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/active", "created_on": "%s", "updated_on": "%s", "parent": {"full_name": "golang/fork"}}`,
+				time.Now().Add(-5*365*24*time.Hour).Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/repositories/rafaeljusto/active/commits":
+			// an active repository has more pages than the analysis needs
+			page := atomic.AddInt32(&activePages, 1)
+			fmt.Fprintf(w, `{"values": [{"hash": "c%d", "date": "%s"}], "next": "%s/repositories/rafaeljusto/active/commits?page=%d"}`,
+				page, time.Now().Format(time.RFC3339), server.URL, page+1)
+
+		case "/repositories/rafaeljusto/deleted":
+			w.WriteHeader(http.StatusNotFound)
+
+		case "/repositories/rafaeljusto/broken":
+			fmt.Fprint(w, `{`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BitbucketURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	data := []struct {
+		description   string
+		path          string
+		expected      bool
+		expectedRule  string
+		expectedError error
+	}{
+		{
+			description:  "it should suppress an unused repository",
+			path:         "bitbucket.org/rafaeljusto/unused",
+			expected:     true,
+			expectedRule: "unused",
+		},
+		{
+			description:  "it should suppress a fast fork",
+			path:         "bitbucket.org/rafaeljusto/fork/subpackage",
+			expected:     true,
+			expectedRule: "fast-fork",
+		},
+		{
+			description: "it should keep an active fork",
+			path:        "bitbucket.org/rafaeljusto/active",
+		},
+		{
+			description: "it should keep a fork with recent commits after an old merged commit",
+			path:        "bitbucket.org/rafaeljusto/merged",
+		},
+		{
+			description:  "it should suppress a deleted repository",
+			path:         "bitbucket.org/rafaeljusto/deleted",
+			expected:     true,
+			expectedRule: "gone",
+		},
+		{
+			description:   "it should fail to decode the JSON response",
+			path:          "bitbucket.org/rafaeljusto/broken",
+			expectedError: gddoexp.ErrorCodeBitbucketParse,
+		},
+		{
+			description:   "it should fail with an unexpected status code",
+			path:          "bitbucket.org/rafaeljusto/unknown",
+			expectedError: gddoexp.ErrorCodeBitbucketStatusCode,
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+
+		if item.expectedError == nil {
+			if err != nil {
+				t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			}
+		} else if !errors.Is(err, item.expectedError) {
+			t.Errorf("[%d] %s: expected error “%v” and got “%v”", i, item.description, item.expectedError, err)
+		}
+
+		if verdict.Suppress != item.expected {
+			t.Errorf("[%d] %s: expected suppress to be %t", i, item.description, item.expected)
+		}
+
+		if verdict.Rule != item.expectedRule {
+			t.Errorf("[%d] %s: expected rule “%s” and got “%s”", i, item.description, item.expectedRule, verdict.Rule)
+		}
+	}
+
+	if pages := atomic.LoadInt32(&activePages); pages > 3 {
+		t.Errorf("expected at most 3 pages of commits and got %d", pages)
+	}
+}
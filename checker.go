@@ -0,0 +1,550 @@
+package gddoexp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/golang/gddo/database"
+	"github.com/google/go-github/github"
+	"github.com/gregjones/httpcache"
+	"github.com/gregjones/httpcache/diskcache"
+)
+
+// Config stores the options used to build a Checker.
+type Config struct {
+	// HTTPClient is the base HTTP client used to send the requests to the
+	// hosting services. Its transport is wrapped to add the local cache and
+	// the Github credentials. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// BaseURL is the Github API endpoint, useful for Github Enterprise
+	// installations (e.g. https://github.example.com/api/v3/). If empty, the
+	// public Github API is used.
+	BaseURL string
+
+	// BitbucketURL is the Bitbucket API endpoint. If empty, the public
+	// Bitbucket API is used.
+	BitbucketURL string
+
+	// GitlabURL is the API endpoint of a self-hosted GitLab instance (e.g.
+	// https://gitlab.example.com/api/v4/). The packages from the instance host
+	// are checked using this endpoint, while the packages from gitlab.com
+	// always use the public GitLab API.
+	GitlabURL string
+
+	// Cache stores the responses locally, avoiding repeated queries to the
+	// hosting services. If nil, no cache is used.
+	Cache httpcache.Cache
+
+	// Auth stores the Github credentials, used to get a more flexible rate
+	// limit. If nil, the requests are sent without authentication.
+	Auth *GithubAuth
+
+	// Pool distributes the requests among several Github credentials. It
+	// can't be used together with Auth.
+	Pool *TokenPool
+
+	// IsCacheResponse detects if a HTTP response was retrieved from cache or
+	// not. If nil, the header added by the local cache is checked.
+	IsCacheResponse func(*http.Response) bool
+
+	// Retry defines how the requests are sent again when the Github rate
+	// limit is reached. If MaxAttempts is zero, DefaultRetryPolicy is used.
+	Retry RetryPolicy
+
+	// GraphQL enables the batch mode of ShouldSuppressPackages and
+	// AreFastForkPackages, where the Github repositories are retrieved with
+	// Github GraphQL API, many repositories per query. The GraphQL endpoint
+	// is built from BaseURL (https://api.github.com/graphql, or
+	// /api/graphql for Github Enterprise), and it only accepts tokens or
+	// Github App credentials.
+	GraphQL bool
+
+	// GraphQLError is called when a GraphQL query of the batch mode fails,
+	// and the packages of the query are retrieved with the REST API instead.
+	// If nil, the failures are ignored.
+	GraphQLError func(error)
+
+	// ResolveVanity enables the resolution of vanity import paths (e.g.
+	// company domains) using the go-import meta tags, as the go tool does. The
+	// package is then analyzed using the repository that backs it. If false,
+	// vanity import paths are reported as not supported. The gopkg.in paths
+	// are always mapped to Github, without network requests.
+	ResolveVanity bool
+}
+
+// Checker analyzes packages using its own Github client, so different
+// checkers can be used at the same time with different credentials, caches or
+// Github endpoints. A checker is safe for concurrent use and should be reused,
+// as it keeps the Github App installation token and the token pool quotas
+// between calls.
+type Checker struct {
+	// Policy stores the thresholds used by the rules and the number of
+	// concurrent agents.
+	Policy Policy
+
+	// Rules is the rule set checked for each package. If nil, DefaultRules is
+	// used.
+	Rules *RuleSet
+
+	// Providers selects the hosting service of each package. It starts with
+	// the Github, Bitbucket and GitLab providers, and new providers can be
+	// registered.
+	Providers *ProviderRegistry
+
+	client          *github.Client
+	httpClient      *http.Client
+	isCacheResponse func(*http.Response) bool
+	retryPolicy     RetryPolicy
+	vanity          *vanityResolver
+
+	// githubHTTPClient sends the requests with the Github credentials, and
+	// graphqlURL is only set in the batch mode.
+	githubHTTPClient *http.Client
+	graphqlURL       *url.URL
+	graphqlError     func(error)
+}
+
+// NewChecker builds a checker with the default policy from the given
+// configuration.
+func NewChecker(config Config) (*Checker, error) {
+	baseURL, err := parseBaseURL(config.BaseURL, defaultBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Github base URL “%s”: %s", config.BaseURL, err)
+	}
+
+	bitbucketURL, err := parseBaseURL(config.BitbucketURL, defaultBitbucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Bitbucket base URL “%s”: %s", config.BitbucketURL, err)
+	}
+
+	gitlabURL, err := parseBaseURL("", defaultGitlabURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var selfHostedGitlabURL *url.URL
+	if config.GitlabURL != "" {
+		if selfHostedGitlabURL, err = parseBaseURL(config.GitlabURL, defaultGitlabURL); err != nil {
+			return nil, fmt.Errorf("invalid GitLab base URL “%s”: %s", config.GitlabURL, err)
+		}
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if config.Cache != nil {
+		transport = &httpcache.Transport{
+			Transport:           transport,
+			Cache:               config.Cache,
+			MarkCachedResponses: true,
+		}
+	}
+
+	// the Github credentials must not be sent to other hosting services
+	cacheClient := &http.Client{
+		Transport:     transport,
+		CheckRedirect: httpClient.CheckRedirect,
+		Jar:           httpClient.Jar,
+		Timeout:       httpClient.Timeout,
+	}
+
+	if config.Auth != nil && config.Pool != nil {
+		return nil, fmt.Errorf("Github credentials and token pool can't be used together")
+	}
+
+	if config.Auth != nil {
+		if err := config.Auth.Validate(); err != nil {
+			return nil, err
+		}
+
+		transport = config.Auth.transport(transport, baseURL)
+	} else if config.Pool != nil {
+		transport = config.Pool.transport(transport, baseURL)
+	}
+
+	githubHTTPClient := &http.Client{
+		Transport:     transport,
+		CheckRedirect: httpClient.CheckRedirect,
+		Jar:           httpClient.Jar,
+		Timeout:       httpClient.Timeout,
+	}
+
+	client := github.NewClient(githubHTTPClient)
+	client.BaseURL = baseURL
+
+	isCacheResponse := config.IsCacheResponse
+	if isCacheResponse == nil {
+		isCacheResponse = func(r *http.Response) bool {
+			return r.Header.Get(httpcache.XFromCache) == "1"
+		}
+	}
+
+	retryPolicy := config.Retry
+	if retryPolicy.MaxAttempts == 0 {
+		retryPolicy = DefaultRetryPolicy()
+	}
+
+	checker := &Checker{
+		Policy:           DefaultPolicy(),
+		Providers:        NewProviderRegistry(),
+		client:           client,
+		httpClient:       cacheClient,
+		isCacheResponse:  isCacheResponse,
+		retryPolicy:      retryPolicy,
+		githubHTTPClient: githubHTTPClient,
+	}
+
+	if config.GraphQL {
+		checker.graphqlURL = graphqlURL(baseURL)
+		checker.graphqlError = config.GraphQLError
+	}
+
+	if config.ResolveVanity {
+		checker.vanity = newVanityResolver(checker)
+	}
+
+	checker.Providers.Register("github.com", githubProvider{checker: checker})
+	checker.Providers.Register("bitbucket.org", bitbucketProvider{checker: checker, baseURL: bitbucketURL})
+	checker.Providers.Register("gitlab.com", gitlabProvider{checker: checker, baseURL: gitlabURL})
+	if selfHostedGitlabURL != nil {
+		checker.Providers.Register(selfHostedGitlabURL.Hostname(), gitlabProvider{checker: checker, baseURL: selfHostedGitlabURL})
+	}
+
+	return checker, nil
+}
+
+// defaultBaseURL is the public Github API endpoint.
+const defaultBaseURL = "https://api.github.com/"
+
+// parseBaseURL parses an API endpoint, ensuring that it ends with a slash so
+// the relative paths are resolved correctly. If the endpoint is empty the
+// default endpoint is used.
+func parseBaseURL(rawURL, defaultURL string) (*url.URL, error) {
+	if rawURL == "" {
+		rawURL = defaultURL
+	}
+
+	if !strings.HasSuffix(rawURL, "/") {
+		rawURL += "/"
+	}
+
+	return url.Parse(rawURL)
+}
+
+var (
+	defaultCache     httpcache.Cache
+	defaultCacheOnce sync.Once
+
+	defaultCheckers      = make(map[defaultCheckerKey]*Checker)
+	defaultCheckersMutex sync.Mutex
+)
+
+// defaultCheckerKey identifies the credentials of a default checker. The
+// Github App is identified by its IDs instead of the pointer, so the callers
+// that build the credentials on each call still reuse the same checker and
+// its installation token.
+type defaultCheckerKey struct {
+	id                string
+	secret            string
+	token             string
+	appID             int64
+	appInstallationID int64
+}
+
+// defaultChecker returns the checker used by the package level functions for
+// the given credentials. The checkers are built on the first use and share a
+// local cache stored in $HOME/.gddoexp. When no credential is informed, the
+// environment variables GITHUB_TOKEN or GITHUB_CLIENT_ID and
+// GITHUB_CLIENT_SECRET are used. The checkers are never released, so programs
+// that use many different credentials should build and reuse their own
+// Checker instead.
+func defaultChecker(auth *GithubAuth) (*Checker, error) {
+	if auth == nil {
+		auth = GithubAuthFromEnv()
+	}
+
+	var key defaultCheckerKey
+	if auth != nil {
+		key = defaultCheckerKey{
+			id:     auth.ID,
+			secret: auth.Secret,
+			token:  auth.Token,
+		}
+
+		if auth.App != nil {
+			key.appID = auth.App.ID
+			key.appInstallationID = auth.App.InstallationID
+		}
+	}
+
+	defaultCheckersMutex.Lock()
+	defer defaultCheckersMutex.Unlock()
+
+	if checker, ok := defaultCheckers[key]; ok {
+		return checker, nil
+	}
+
+	defaultCacheOnce.Do(func() {
+		defaultCache = diskcache.New(path.Join(os.Getenv("HOME"), ".gddoexp"))
+	})
+
+	checker, err := NewChecker(Config{
+		Cache: defaultCache,
+		Auth:  auth,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defaultCheckers[key] = checker
+	return checker, nil
+}
+
+// GithubAuthFromEnv builds the Github credentials from the environment
+// variables GITHUB_TOKEN or GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET. It
+// returns nil when there's no credential in the environment.
+func GithubAuthFromEnv() *GithubAuth {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return &GithubAuth{Token: token}
+	}
+
+	id, secret := os.Getenv("GITHUB_CLIENT_ID"), os.Getenv("GITHUB_CLIENT_SECRET")
+	if id != "" || secret != "" {
+		return &GithubAuth{ID: id, Secret: secret}
+	}
+
+	return nil
+}
+
+// withPolicy returns a copy of the checker, sharing the same Github client,
+// that uses the given policy.
+func (c *Checker) withPolicy(policy Policy) *Checker {
+	checker := *c
+	checker.Policy = policy
+	return &checker
+}
+
+// rules returns the rule set that should be checked.
+func (c *Checker) rules() *RuleSet {
+	if c.Rules == nil {
+		return DefaultRules
+	}
+
+	return c.Rules
+}
+
+// ShouldSuppressPackage determinate if a package should be suppressed or not.
+// It's necessary to inform the GoDoc database to retrieve current stored
+// package information.
+func (c *Checker) ShouldSuppressPackage(ctx context.Context, p database.Package, db gddoDB) (suppress, cache bool, err error) {
+	verdict, cache, err := c.EvaluatePackage(ctx, p, db)
+	return verdict.Suppress, cache, err
+}
+
+// EvaluatePackage works like ShouldSuppressPackage, but instead of only
+// returning the decision, it returns the verdict with the rule that took the
+// decision and the evidences that it used.
+func (c *Checker) EvaluatePackage(ctx context.Context, p database.Package, db gddoDB) (verdict Verdict, cache bool, err error) {
+	verdict, cache, _, _, err = c.evaluatePackage(ctx, p, db, nil)
+	return verdict, cache, err
+}
+
+// evaluatePackage checks the rules for the package, sharing the hosting
+// service responses through the memo when informed. It also returns the
+// number of shared responses used and the root of the repository retrieved.
+// An invalid policy is reported before any request is sent, as a zero unused
+// period would suppress every package.
+func (c *Checker) evaluatePackage(ctx context.Context, p database.Package, db gddoDB, memo *fetchMemo) (Verdict, bool, int, string, error) {
+	if err := c.Policy.Validate(); err != nil {
+		return Verdict{}, true, 0, "", fmt.Errorf("invalid policy: %w", err)
+	}
+
+	resolved, ok, err := c.resolve(p.Path)
+	if err != nil {
+		return Verdict{}, true, 0, "", err
+	}
+
+	s := c.newSubject(ctx, p, db)
+	s.resolved = resolved
+	s.resolvedLoaded = ok
+	s.memo = memo
+
+	verdict, err := c.rules().evaluate(s)
+	verdict.RepositoryURL = s.resolved.repoURL
+	return verdict, s.Cache(), s.Shared(), s.sharedRoot(), err
+}
+
+// ShouldSuppressPackages determinate if a package should be suppressed or not,
+// but unlike ShouldSuppressPackage, it can process a list of packages
+// concurrently, using the number of agents defined in the policy. Each
+// repository is retrieved only once, and its response is shared by all
+// packages of the repository. In the batch mode the Github repositories are
+// retrieved in groups before being processed. When the context is cancelled
+// the remaining packages are not processed and the channel is closed.
+func (c *Checker) ShouldSuppressPackages(ctx context.Context, packages []database.Package, db gddoDB) <-chan SuppressResponse {
+	agents := c.Policy.agents()
+	out := make(chan SuppressResponse, agents)
+	memo := newFetchMemo()
+
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(agents)
+
+		in := make(chan database.Package)
+
+		for i := 0; i < agents; i++ {
+			go func() {
+				for p := range in {
+					verdict, cache, shared, root, err := c.evaluatePackage(ctx, p, db, memo)
+					out <- SuppressResponse{
+						Package:    p,
+						Suppress:   verdict.Suppress,
+						Verdict:    verdict,
+						Cache:      cache,
+						Shared:     shared,
+						Repository: root,
+						Error:      err,
+					}
+				}
+
+				wg.Done()
+			}()
+		}
+
+	feed:
+		for len(packages) > 0 {
+			batch := packages
+			if c.graphqlURL != nil {
+				var err error
+				if batch, err = c.prefetchGithub(ctx, packages, memo); err != nil {
+					break feed
+				}
+			}
+
+			for _, pkg := range batch {
+				select {
+				case in <- pkg:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+
+			packages = packages[len(batch):]
+		}
+
+		close(in)
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// IsFastForkPackage identifies if a package is a fork created only to make
+// small changes for a pull request.
+func (c *Checker) IsFastForkPackage(ctx context.Context, p database.Package) (fastFork, cache bool, err error) {
+	fastFork, cache, _, _, err = c.isFastForkPackage(ctx, p, nil)
+	return fastFork, cache, err
+}
+
+// isFastForkPackage checks if the package is a fast fork, sharing the hosting
+// service responses through the memo when informed. It also returns the
+// number of shared responses used and the root of the repository retrieved.
+// An invalid policy is reported before any request is sent.
+func (c *Checker) isFastForkPackage(ctx context.Context, p database.Package, memo *fetchMemo) (bool, bool, int, string, error) {
+	if err := c.Policy.Validate(); err != nil {
+		return false, true, 0, "", fmt.Errorf("invalid policy: %w", err)
+	}
+
+	resolved, ok, err := c.resolve(p.Path)
+	if err != nil {
+		return false, true, 0, "", err
+	}
+
+	s := c.newSubject(ctx, p, nil)
+	s.resolved = resolved
+	s.resolvedLoaded = ok
+	s.memo = memo
+
+	fastFork, _, err := isFastForkPackage(s)
+	return fastFork, s.Cache(), s.Shared(), s.sharedRoot(), err
+}
+
+// AreFastForkPackages determinate if a package is a fast fork or not,
+// but unlike IsFastForkPackage, it can process a list of packages
+// concurrently, using the number of agents defined in the policy. Each
+// repository is retrieved only once, and its response is shared by all
+// packages of the repository. In the batch mode the Github repositories are
+// retrieved in groups before being processed. When the context is cancelled
+// the remaining packages are not processed and the channel is closed.
+func (c *Checker) AreFastForkPackages(ctx context.Context, packages []database.Package) <-chan FastForkResponse {
+	agents := c.Policy.agents()
+	out := make(chan FastForkResponse, agents)
+	memo := newFetchMemo()
+
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(agents)
+
+		in := make(chan database.Package)
+
+		for i := 0; i < agents; i++ {
+			go func() {
+				for p := range in {
+					fastFork, cache, shared, root, err := c.isFastForkPackage(ctx, p, memo)
+					out <- FastForkResponse{
+						Path:       p.Path,
+						FastFork:   fastFork,
+						Cache:      cache,
+						Shared:     shared,
+						Repository: root,
+						Error:      err,
+					}
+				}
+
+				wg.Done()
+			}()
+		}
+
+	feed:
+		for len(packages) > 0 {
+			batch := packages
+			if c.graphqlURL != nil {
+				var err error
+				if batch, err = c.prefetchGithub(ctx, packages, memo); err != nil {
+					break feed
+				}
+			}
+
+			for _, pkg := range batch {
+				select {
+				case in <- pkg:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+
+			packages = packages[len(batch):]
+		}
+
+		close(in)
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
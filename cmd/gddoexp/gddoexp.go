@@ -1,35 +1,85 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/cheggaaa/pb"
 	"github.com/golang/gddo/database"
-	"github.com/gregjones/httpcache"
 	"github.com/rafaeljusto/gddoexp"
+	"github.com/rafaeljusto/gddoexp/internal/cmdutil"
 )
 
-func init() {
-	gddoexp.IsCacheResponse = func(r *http.Response) bool {
-		return r.Header.Get(httpcache.XFromCache) == "1"
-	}
-}
-
 func main() {
+	checkerFlags := cmdutil.NewCheckerFlags()
+	policyFlags := cmdutil.NewPolicyFlags()
+	policyFlags.RegisterSuppressFlags()
 	output := flag.String("output", "gddoexp.out", "Output file")
 	progress := flag.Bool("progress", false, "Show a progress bar")
+	deprecated := flag.Bool("deprecated", false, "Check archived repositories before the importers, flagging imported packages as deprecated")
+	redirect := flag.Bool("redirect", false, "Check moved repositories before the importers, redirecting imported packages to the canonical path")
+	successor := flag.Bool("successor", false, "Check if forks became the maintained successors of their parents, before the importers")
+	canonical := flag.String("canonical", "", "File containing the import path prefixes of the canonical packages, one per line, to suppress their copies")
 	flag.Parse()
 
+	policy, err := policyFlags.Policy()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	auth, err := checkerFlags.Auth()
+	if err != nil {
+		fmt.Println(err)
+		flag.PrintDefaults()
+		return
+	}
+
+	checker, err := checkerFlags.NewChecker(auth, policy)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if *deprecated || *redirect || *successor || *canonical != "" {
+		checker.Rules = gddoexp.NewDefaultRuleSet()
+	}
+
+	if *deprecated {
+		checker.Rules.MoveBefore("archived", "importers")
+	}
+
+	if *redirect {
+		checker.Rules.MoveBefore("moved", "importers")
+	}
+
+	if *successor {
+		checker.Rules.InsertBefore("importers", gddoexp.SuccessorRule{})
+	}
+
 	db, err := database.New()
 	if err != nil {
 		fmt.Println("error connecting to database:", err)
 		return
 	}
 
+	if *canonical != "" {
+		index, err := readCanonical(db, *canonical)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		checker.Rules.InsertBefore("gone", gddoexp.DuplicateRule{Canonical: index})
+	}
+
 	pkgs, err := db.AllPackages()
 	if err != nil {
 		fmt.Println("error retrieving all packages:", err)
@@ -52,9 +102,20 @@ func main() {
 		progressBar = pb.StartNew(len(pkgs))
 	}
 
-	var cache int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// on Ctrl-C we stop sending requests to Github and finish the log
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
 
-	for response := range gddoexp.ShouldSuppressPackages(pkgs, db) {
+	var cache, shared int
+
+	for response := range checker.ShouldSuppressPackages(ctx, pkgs, db) {
 		if progress != nil && *progress {
 			progressBar.Increment()
 		}
@@ -63,13 +124,24 @@ func main() {
 			cache++
 		}
 
+		shared += response.Shared
+
 		if response.Error != nil {
 			log.Println(response.Error)
 		} else if response.Suppress {
-			log.Printf("package “%s” should be suppressed\n", response.Package.Path)
+			verdict, err := json.Marshal(response.Verdict)
+			if err != nil {
+				log.Printf("error encoding verdict of package “%s”: %s\n", response.Package.Path, err)
+			}
+
+			log.Printf("package “%s” should be suppressed (%s): %s\n", response.Package.Path, response.Verdict.Reason, verdict)
 			if progress != nil && !*progress {
 				fmt.Println(response.Package.Path)
 			}
+		} else if response.Verdict.Deprecated {
+			log.Printf("package “%s” is deprecated (%s)\n", response.Package.Path, response.Verdict.Reason)
+		} else if response.Verdict.Supersedes != "" {
+			log.Printf("package “%s” supersedes package “%s” (%s)\n", response.Package.Path, response.Verdict.Supersedes, response.Verdict.Reason)
 		}
 	}
 
@@ -77,6 +149,52 @@ func main() {
 		progressBar.Finish()
 	}
 
+	if ctx.Err() != nil {
+		log.Println("Interrupted, not all packages were analyzed")
+	}
+
 	log.Println("Cache hits:", cache)
+	log.Println("Shared responses:", shared)
 	log.Println("END")
 }
+
+// readCanonical builds the index of the canonical packages, that are the
+// packages from GoDoc database with one of the import path prefixes listed in
+// the file, one per line.
+func readCanonical(db *database.Database, file string) (*gddoexp.FingerprintIndex, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening canonical file “%s”: %s", file, err)
+	}
+	defer f.Close()
+
+	var prefixes []string
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if prefix := strings.TrimSpace(scanner.Text()); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading canonical file “%s”: %s", file, err)
+	}
+
+	index := gddoexp.NewFingerprintIndex()
+	err = db.Do(func(pkg *database.PackageInfo) error {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(pkg.PDoc.ImportPath, prefix) {
+				index.Add(pkg.PDoc)
+				break
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error indexing canonical packages: %s", err)
+	}
+
+	return index, nil
+}
@@ -2,42 +2,48 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/cheggaaa/pb"
 	"github.com/golang/gddo/database"
-	"github.com/rafaeljusto/gddoexp"
+	"github.com/rafaeljusto/gddoexp/internal/cmdutil"
 )
 
 func main() {
-	clientID := flag.String("id", "", "Github client ID")
-	clientSecret := flag.String("secret", "", "Github client secret")
+	checkerFlags := cmdutil.NewCheckerFlags()
+	policyFlags := cmdutil.NewPolicyFlags()
 	file := flag.String("file", "", "File containing the list of packages")
 	output := flag.String("output", "gddofork.out", "Output file")
 	progress := flag.Bool("progress", false, "Show a progress bar")
 	flag.Parse()
 
-	var auth *gddoexp.GithubAuth
-	if (clientID != nil && *clientID != "") || (clientSecret != nil && *clientSecret != "") {
-		if *clientID == "" || *clientSecret == "" {
-			fmt.Println("to enable Gthub authentication, you need to inform the id and secret")
-			flag.PrintDefaults()
-			return
-		}
+	policy, err := policyFlags.Policy()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-		auth = &gddoexp.GithubAuth{
-			ID:     *clientID,
-			Secret: *clientSecret,
-		}
+	auth, err := checkerFlags.Auth()
+	if err != nil {
+		fmt.Println(err)
+		flag.PrintDefaults()
+		return
+	}
+
+	checker, err := checkerFlags.NewChecker(auth, policy)
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
 	var pkgs []database.Package
-	var err error
 
 	if file != nil && *file != "" {
 		pkgs, err = readFromFile(*file)
@@ -66,9 +72,20 @@ func main() {
 		progressBar = pb.StartNew(len(pkgs))
 	}
 
-	var cache int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// on Ctrl-C we stop sending requests to Github and finish the log
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
 
-	for response := range gddoexp.AreFastForkPackages(pkgs, auth) {
+	var cache, shared int
+
+	for response := range checker.AreFastForkPackages(ctx, pkgs) {
 		if progress != nil && *progress {
 			progressBar.Increment()
 		}
@@ -77,6 +94,8 @@ func main() {
 			cache++
 		}
 
+		shared += response.Shared
+
 		if response.Error != nil {
 			log.Println(response.Error)
 		} else if response.FastFork {
@@ -93,7 +112,12 @@ func main() {
 		progressBar.Finish()
 	}
 
+	if ctx.Err() != nil {
+		log.Println("Interrupted, not all packages were analyzed")
+	}
+
 	log.Println("Cache hits:", cache)
+	log.Println("Shared responses:", shared)
 	log.Println("END")
 }
 
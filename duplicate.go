@@ -0,0 +1,252 @@
+package gddoexp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+// docDB is implemented by GoDoc databases that also return the documentation
+// stored for a package, like the gddo database. The duplicate rule needs it to
+// fingerprint the package.
+type docDB interface {
+	GetDoc(path string) (*doc.Package, time.Time, error)
+}
+
+// the gddo database must return the documentation, or the duplicate rule never
+// fingerprints the packages
+var _ docDB = (*database.Database)(nil)
+
+// minFingerprintDecls is the minimum number of exported declarations of a
+// package to compute its fingerprint. Small packages with the same name and
+// files, like the ones generated by a tool, aren't necessarily copies.
+const minFingerprintDecls = 3
+
+// Fingerprint identifies the content of a package from the documentation
+// stored by GoDoc: the package name, the package documentation, the names of
+// the source files, the imports from the standard library and the names and
+// declarations of the exported API. Other imports are left out, as a copy
+// usually rewrites the import paths of the project that it copied. It returns
+// an empty string when there's no source file or not enough exported
+// declarations to compare.
+func Fingerprint(pdoc *doc.Package) string {
+	if pdoc == nil || len(pdoc.Files) == 0 {
+		return ""
+	}
+
+	decls := exportedDecls(pdoc)
+	if len(decls) < minFingerprintDecls {
+		return ""
+	}
+
+	var files []string
+	for _, file := range pdoc.Files {
+		files = append(files, path.Base(file.Name))
+	}
+	sort.Strings(files)
+
+	var imports []string
+	for _, importPath := range pdoc.Imports {
+		// standard library packages don't have a domain in the first element
+		if !strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			imports = append(imports, importPath)
+		}
+	}
+	sort.Strings(imports)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "name %s\n", pdoc.Name)
+	fmt.Fprintf(hash, "doc %q\n", strings.TrimSpace(pdoc.Doc))
+	fmt.Fprintf(hash, "files %s\n", strings.Join(files, " "))
+	fmt.Fprintf(hash, "imports %s\n", strings.Join(imports, " "))
+	for _, decl := range decls {
+		fmt.Fprintf(hash, "decl %q\n", decl)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// exportedDecls lists the exported API of the package, with the kind, the name
+// and the declaration of each function, method, type, constant and variable.
+// The list is sorted, so the order of the source files doesn't matter.
+func exportedDecls(pdoc *doc.Package) []string {
+	var decls []string
+
+	addValues := func(kind string, values []*doc.Value) {
+		for _, value := range values {
+			decls = append(decls, kind+" "+value.Decl.Text)
+		}
+	}
+
+	addFuncs := func(funcs []*doc.Func) {
+		for _, f := range funcs {
+			decls = append(decls, "func "+f.Recv+" "+f.Name+" "+f.Decl.Text)
+		}
+	}
+
+	addValues("const", pdoc.Consts)
+	addValues("var", pdoc.Vars)
+	addFuncs(pdoc.Funcs)
+
+	for _, t := range pdoc.Types {
+		decls = append(decls, "type "+t.Name+" "+t.Decl.Text)
+		addValues("const", t.Consts)
+		addValues("var", t.Vars)
+		addFuncs(t.Funcs)
+		addFuncs(t.Methods)
+	}
+
+	sort.Strings(decls)
+	return decls
+}
+
+// FingerprintIndex stores the fingerprints of the known canonical packages,
+// like the packages from golang.org/x, so copies of them can be found. It is
+// safe to add packages while other packages are being checked.
+type FingerprintIndex struct {
+	mutex     sync.RWMutex
+	canonical map[string]string
+
+	// packages stores the import paths of all canonical packages, including
+	// the ones without fingerprint or with the same content of another
+	// canonical package.
+	packages map[string]bool
+}
+
+// NewFingerprintIndex builds an empty index.
+func NewFingerprintIndex() *FingerprintIndex {
+	return &FingerprintIndex{
+		canonical: make(map[string]string),
+		packages:  make(map[string]bool),
+	}
+}
+
+// Add stores the fingerprint of a canonical package, identified by its import
+// path. When two canonical packages have the same content the first one is
+// kept, but both are still canonical. It returns false when the package has no
+// fingerprint.
+func (f *FingerprintIndex) Add(pdoc *doc.Package) bool {
+	if pdoc == nil {
+		return false
+	}
+
+	fingerprint := Fingerprint(pdoc)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.packages[pdoc.ImportPath] = true
+	if fingerprint == "" {
+		return false
+	}
+
+	if _, ok := f.canonical[fingerprint]; !ok {
+		f.canonical[fingerprint] = pdoc.ImportPath
+	}
+	return true
+}
+
+// Contains returns true when the package, identified by its import path, was
+// added to the index as a canonical package.
+func (f *FingerprintIndex) Contains(path string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.packages[path]
+}
+
+// Lookup returns the import path of the canonical package with the
+// fingerprint, if there's one.
+func (f *FingerprintIndex) Lookup(fingerprint string) (string, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	canonical, ok := f.canonical[fingerprint]
+	return canonical, ok
+}
+
+// Len returns the number of fingerprints in the index.
+func (f *FingerprintIndex) Len() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return len(f.canonical)
+}
+
+// DuplicateRule suppresses the package when it's a mirror or a vendored copy
+// of a canonical package, like an unmodified copy of golang.org/x/net under
+// another account. Copies aren't always marked as forks by the hosting
+// service, so the fast fork rule doesn't find them. The package content is
+// compared by the fingerprint of its GoDoc documentation, and only copies
+// without importers are suppressed. The rule isn't in the default rules, as it
+// needs the index of canonical packages and a GoDoc database that returns the
+// package documentation.
+type DuplicateRule struct {
+	// Canonical stores the fingerprints of the canonical packages.
+	Canonical *FingerprintIndex
+}
+
+// Name identifies the rule.
+func (DuplicateRule) Name() string {
+	return "duplicate"
+}
+
+// Check compares the fingerprint of the package with the canonical packages.
+func (r DuplicateRule) Check(s *Subject) (RuleResult, error) {
+	if r.Canonical == nil {
+		return RuleResult{Reason: "no canonical packages to compare"}, nil
+	}
+
+	// canonical packages with the same content aren't copies of each other
+	if r.Canonical.Contains(s.Package.Path) {
+		return RuleResult{Reason: "canonical package"}, nil
+	}
+
+	pdoc, err := s.Doc()
+	if err != nil {
+		return RuleResult{}, err
+	} else if pdoc == nil {
+		return RuleResult{Reason: "documentation not available"}, nil
+	}
+
+	fingerprint := Fingerprint(pdoc)
+	if fingerprint == "" {
+		return RuleResult{Reason: "not enough content to compare"}, nil
+	}
+
+	evidence := Evidence{"fingerprint": fingerprint}
+
+	canonical, ok := r.Canonical.Lookup(fingerprint)
+	if !ok {
+		return RuleResult{Reason: "not a copy of a canonical package", Evidence: evidence}, nil
+	}
+	evidence["canonical"] = canonical
+
+	importers, err := s.ImporterCount()
+	if err != nil {
+		return RuleResult{}, err
+	}
+	evidence["importers"] = importers
+
+	if importers > 0 {
+		return RuleResult{
+			Reason:   fmt.Sprintf("copy of %s imported by %d projects", canonical, importers),
+			Evidence: evidence,
+		}, nil
+	}
+
+	return RuleResult{
+		Matched:     true,
+		Suppress:    true,
+		Reason:      fmt.Sprintf("copy of %s", canonical),
+		Evidence:    evidence,
+		DuplicateOf: canonical,
+	}, nil
+}
@@ -0,0 +1,295 @@
+package gddoexp_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestFingerprint(t *testing.T) {
+	parse := &doc.Func{Name: "Parse", Decl: doc.Code{Text: "func Parse(r io.Reader) (*Node, error)"}}
+	render := &doc.Func{Name: "Render", Decl: doc.Code{Text: "func Render(w io.Writer, n *Node) error"}}
+	node := &doc.Type{
+		Name:    "Node",
+		Decl:    doc.Code{Text: "type Node struct {\n\tData string\n}"},
+		Methods: []*doc.Func{{Name: "AppendChild", Recv: "*Node", Decl: doc.Code{Text: "func (n *Node) AppendChild(c *Node)"}}},
+	}
+
+	canonical := &doc.Package{
+		ImportPath: "golang.org/x/net/html",
+		Name:       "html",
+		Doc:        "Package html implements an HTML5-compliant tokenizer and parser.",
+		Files:      []*doc.File{{Name: "parse.go"}, {Name: "token.go"}},
+		Imports:    []string{"bytes", "golang.org/x/net/html/atom", "io"},
+		Funcs:      []*doc.Func{parse, render},
+		Types:      []*doc.Type{node},
+	}
+
+	data := []struct {
+		description string
+		pdoc        *doc.Package
+		expected    bool
+	}{
+		{
+			description: "it should match a copy with rewritten imports",
+			pdoc: &doc.Package{
+				ImportPath: "github.com/someone/net/html",
+				Name:       "html",
+				Doc:        "Package html implements an HTML5-compliant tokenizer and parser.\n",
+				Files:      []*doc.File{{Name: "token.go"}, {Name: "parse.go"}},
+				Imports:    []string{"io", "github.com/someone/net/html/atom", "bytes"},
+				Funcs:      []*doc.Func{render, parse},
+				Types:      []*doc.Type{node},
+			},
+			expected: true,
+		},
+		{
+			description: "it should not match a package with different files",
+			pdoc: &doc.Package{
+				ImportPath: "github.com/someone/html",
+				Name:       "html",
+				Doc:        "Package html implements an HTML5-compliant tokenizer and parser.",
+				Files:      []*doc.File{{Name: "html.go"}},
+				Imports:    []string{"bytes", "io"},
+				Funcs:      []*doc.Func{parse, render},
+				Types:      []*doc.Type{node},
+			},
+		},
+		{
+			description: "it should not match a package with a different documentation",
+			pdoc: &doc.Package{
+				ImportPath: "github.com/someone/net/html",
+				Name:       "html",
+				Doc:        "Package html parses HTML documents.",
+				Files:      []*doc.File{{Name: "parse.go"}, {Name: "token.go"}},
+				Imports:    []string{"bytes", "io"},
+				Funcs:      []*doc.Func{parse, render},
+				Types:      []*doc.Type{node},
+			},
+		},
+		{
+			description: "it should not match a package with a different API",
+			pdoc: &doc.Package{
+				ImportPath: "github.com/someone/net/html",
+				Name:       "html",
+				Doc:        "Package html implements an HTML5-compliant tokenizer and parser.",
+				Files:      []*doc.File{{Name: "parse.go"}, {Name: "token.go"}},
+				Imports:    []string{"bytes", "io"},
+				Funcs: []*doc.Func{
+					parse,
+					{Name: "Render", Decl: doc.Code{Text: "func Render(w io.Writer, n *Node, indent bool) error"}},
+				},
+				Types: []*doc.Type{node},
+			},
+		},
+	}
+
+	for i, item := range data {
+		if match := gddoexp.Fingerprint(item.pdoc) == gddoexp.Fingerprint(canonical); match != item.expected {
+			t.Errorf("[%d] %s: expected match to be %t", i, item.description, item.expected)
+		}
+	}
+
+	if fingerprint := gddoexp.Fingerprint(&doc.Package{Name: "empty"}); fingerprint != "" {
+		t.Errorf("unexpected fingerprint “%s” for a package without source files", fingerprint)
+	}
+
+	small := &doc.Package{
+		Name:  "version",
+		Files: []*doc.File{{Name: "version.go"}},
+		Consts: []*doc.Value{
+			{Decl: doc.Code{Text: "const Version = \"1.0.0\""}},
+		},
+	}
+	if fingerprint := gddoexp.Fingerprint(small); fingerprint != "" {
+		t.Errorf("unexpected fingerprint “%s” for a package with too little content", fingerprint)
+	}
+}
+
+func TestDuplicateRule(t *testing.T) {
+	netHTML := &doc.Package{
+		ImportPath: "golang.org/x/net/html",
+		Name:       "html",
+		Doc:        "Package html implements an HTML5-compliant tokenizer and parser.",
+		Files:      []*doc.File{{Name: "parse.go"}, {Name: "token.go"}},
+		Imports:    []string{"bytes", "golang.org/x/net/html/atom", "io"},
+		Funcs: []*doc.Func{
+			{Name: "Parse", Decl: doc.Code{Text: "func Parse(r io.Reader) (*Node, error)"}},
+			{Name: "Render", Decl: doc.Code{Text: "func Render(w io.Writer, n *Node) error"}},
+		},
+		Types: []*doc.Type{
+			{Name: "Node", Decl: doc.Code{Text: "type Node struct {\n\tData string\n}"}},
+		},
+	}
+
+	copyOf := func(importPath string) *doc.Package {
+		pdoc := *netHTML
+		pdoc.ImportPath = importPath
+		return &pdoc
+	}
+
+	docs := map[string]*doc.Package{
+		"golang.org/x/net/html":        netHTML,
+		"github.com/mirror/net/html":   copyOf("github.com/mirror/net/html"),
+		"github.com/imported/net/html": copyOf("github.com/imported/net/html"),
+		"github.com/golang/net/html":   copyOf("github.com/golang/net/html"),
+		"github.com/rafaeljusto/gddoexp": {
+			ImportPath: "github.com/rafaeljusto/gddoexp",
+			Name:       "gddoexp",
+			Doc:        "Package gddoexp is a GoDoc experiment.",
+			Files:      []*doc.File{{Name: "gddoexp.go"}},
+		},
+		"github.com/rafaeljusto/dns": {
+			ImportPath: "github.com/rafaeljusto/dns",
+			Name:       "dns",
+			Doc:        "Package dns implements a DNS library.",
+			Files:      []*doc.File{{Name: "dns.go"}},
+		},
+	}
+
+	index := gddoexp.NewFingerprintIndex()
+	index.Add(netHTML)
+	index.Add(docs["github.com/golang/net/html"])
+	index.Add(docs["github.com/rafaeljusto/gddoexp"])
+
+	db := docDatabaseMock{
+		databaseMock: databaseMock{
+			importerCountMock: func(path string) (int, error) {
+				if path == "github.com/imported/net/html" {
+					return 2, nil
+				}
+				return 0, nil
+			},
+		},
+		getDocMock: func(path string) (*doc.Package, error) {
+			if path == "github.com/broken/pkg" {
+				return nil, errors.New("connection refused")
+			}
+			return docs[path], nil
+		},
+	}
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.DuplicateRule{Canonical: index})
+
+	fingerprint := gddoexp.Fingerprint(netHTML)
+
+	data := []struct {
+		description   string
+		path          string
+		db            interface{ ImporterCount(string) (int, error) }
+		expected      gddoexp.Verdict
+		expectedError error
+	}{
+		{
+			description: "it should suppress a copy without importers",
+			path:        "github.com/mirror/net/html",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "duplicate",
+				Reason:   "copy of golang.org/x/net/html",
+				Evidence: gddoexp.Evidence{
+					"fingerprint": fingerprint,
+					"canonical":   "golang.org/x/net/html",
+					"importers":   0,
+				},
+				DuplicateOf: "golang.org/x/net/html",
+				Checked:     []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep a copy imported by other projects",
+			path:        "github.com/imported/net/html",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep the canonical package",
+			path:        "github.com/rafaeljusto/gddoexp",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep a canonical package with the same content of another one",
+			path:        "github.com/golang/net/html",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep a package with a different content",
+			path:        "github.com/rafaeljusto/dns",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep a package without documentation",
+			path:        "github.com/unknown/pkg",
+			db:          db,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description: "it should keep a package when the database doesn't store documentation",
+			path:        "github.com/mirror/net/html",
+			db:          db.databaseMock,
+			expected: gddoexp.Verdict{
+				Checked: []string{"duplicate"},
+			},
+		},
+		{
+			description:   "it should fail when the documentation can't be retrieved",
+			path:          "github.com/broken/pkg",
+			db:            db,
+			expectedError: gddoexp.ErrorCodeRetrieveDoc,
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, item.db)
+
+		if item.expectedError != nil {
+			if !errors.Is(err, item.expectedError) {
+				t.Errorf("[%d] %s: expected error “%v” and got “%v”", i, item.description, item.expectedError, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+type docDatabaseMock struct {
+	databaseMock
+	getDocMock func(string) (*doc.Package, error)
+}
+
+func (d docDatabaseMock) GetDoc(path string) (*doc.Package, time.Time, error) {
+	pdoc, err := d.getDocMock(path)
+	return pdoc, time.Time{}, err
+}
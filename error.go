@@ -8,8 +8,9 @@ const (
 	// retrieving the import counter from GoDoc database.
 	ErrorCodeRetrieveImportCounts ErrorCode = iota
 
-	// ErrorCodeNonGithub is used when the path isn't from Github.
-	ErrorCodeNonGithub
+	// ErrorCodeUnsupportedHost is used when there's no provider for the
+	// hosting service of the path.
+	ErrorCodeUnsupportedHost
 
 	// ErrorCodeGithubFetch is used when there's a problem while retrieving
 	// information from Guthub API.
@@ -30,22 +31,100 @@ const (
 	// ErrorCodeGithubParse is used when there's a problem while parsing the
 	// JSON response.
 	ErrorCodeGithubParse
+
+	// ErrorCodeGithubUnavailable is used when the repository was blocked in
+	// Github, usually after a DMCA takedown (status 451 Unavailable For Legal
+	// Reasons).
+	ErrorCodeGithubUnavailable
+
+	// ErrorCodeBitbucketFetch is used when there's a problem while retrieving
+	// information from Bitbucket API.
+	ErrorCodeBitbucketFetch
+
+	// ErrorCodeBitbucketNotFound is used when the repository wasn't found in
+	// Bitbucket (status 404 Not Found).
+	ErrorCodeBitbucketNotFound
+
+	// ErrorCodeBitbucketStatusCode is used when the response status code from
+	// Bitbucket isn't one of the following: 200 OK or 404 Not Found.
+	ErrorCodeBitbucketStatusCode
+
+	// ErrorCodeBitbucketParse is used when there's a problem while parsing the
+	// JSON response from Bitbucket.
+	ErrorCodeBitbucketParse
+
+	// ErrorCodeGitlabFetch is used when there's a problem while retrieving
+	// information from GitLab API.
+	ErrorCodeGitlabFetch
+
+	// ErrorCodeGitlabNotFound is used when the project wasn't found in GitLab
+	// (status 404 Not Found).
+	ErrorCodeGitlabNotFound
+
+	// ErrorCodeGitlabStatusCode is used when the response status code from
+	// GitLab isn't one of the following: 200 OK or 404 Not Found.
+	ErrorCodeGitlabStatusCode
+
+	// ErrorCodeGitlabParse is used when there's a problem while parsing the
+	// JSON response from GitLab.
+	ErrorCodeGitlabParse
+
+	// ErrorCodeVanityFetch is used when there's a problem while retrieving
+	// the go-import meta tags of a vanity import path.
+	ErrorCodeVanityFetch
+
+	// ErrorCodeVanityParse is used when the go-import meta tags of a vanity
+	// import path can't be decoded or are ambiguous.
+	ErrorCodeVanityParse
+
+	// ErrorCodeRetrieveDoc is used whenever a error occurs while retrieving
+	// the package documentation from GoDoc database.
+	ErrorCodeRetrieveDoc
 )
 
+// ErrorCodeNonGithub is used when there's no provider for the hosting service
+// of the path.
+//
+// Deprecated: packages outside Github are also supported now, use
+// ErrorCodeUnsupportedHost instead.
+const ErrorCodeNonGithub = ErrorCodeUnsupportedHost
+
 // ErrorCode stores the type of the error. Useful when we want to perform
-// different actions depending on the error type.
+// different actions depending on the error type. An ErrorCode can be used as
+// target of errors.Is, for example:
+//
+//	if errors.Is(err, gddoexp.ErrorCodeGithubNotFound) {
+//		// repository was deleted
+//	}
 type ErrorCode int
 
+// Error returns the human readable message of the error code.
+func (e ErrorCode) Error() string {
+	return errorCodeMessage[e]
+}
+
 // errorCodeMessage translates an error code to an human understandable
 // message.
 var errorCodeMessage = map[ErrorCode]string{
 	ErrorCodeRetrieveImportCounts: "error retrieving import counts",
-	ErrorCodeNonGithub:            "not a Github project",
+	ErrorCodeUnsupportedHost:      "hosting service not supported",
 	ErrorCodeGithubFetch:          "error retrieving information from Github",
 	ErrorCodeGithubForbidden:      "ratelimit reached in Github API",
 	ErrorCodeGithubNotFound:       "not found in Github",
 	ErrorCodeGithubStatusCode:     "unexpected status code from Github",
 	ErrorCodeGithubParse:          "error decoding Github response",
+	ErrorCodeGithubUnavailable:    "unavailable for legal reasons in Github",
+	ErrorCodeBitbucketFetch:       "error retrieving information from Bitbucket",
+	ErrorCodeBitbucketNotFound:    "not found in Bitbucket",
+	ErrorCodeBitbucketStatusCode:  "unexpected status code from Bitbucket",
+	ErrorCodeBitbucketParse:       "error decoding Bitbucket response",
+	ErrorCodeGitlabFetch:          "error retrieving information from GitLab",
+	ErrorCodeGitlabNotFound:       "not found in GitLab",
+	ErrorCodeGitlabStatusCode:     "unexpected status code from GitLab",
+	ErrorCodeGitlabParse:          "error decoding GitLab response",
+	ErrorCodeVanityFetch:          "error retrieving go-import meta tags",
+	ErrorCodeVanityParse:          "error decoding go-import meta tags",
+	ErrorCodeRetrieveDoc:          "error retrieving package documentation",
 }
 
 // Error stores extra information from a low level error indicating the
@@ -73,3 +152,23 @@ func (e Error) Error() string {
 
 	return fmt.Sprintf("gddoexp: [%s] %s: %s", e.Path, errorCodeMessage[e.Code], e.Details)
 }
+
+// Unwrap returns the low level error, allowing errors.Is and errors.As to
+// inspect it.
+func (e Error) Unwrap() error {
+	return e.Details
+}
+
+// Is reports if the error matches the target. The target can be an ErrorCode
+// or an Error. When the target is an Error without path, only the code is
+// compared.
+func (e Error) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCode:
+		return e.Code == t
+	case Error:
+		return e.Code == t.Code && (t.Path == "" || t.Path == e.Path)
+	}
+
+	return false
+}
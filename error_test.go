@@ -1,7 +1,9 @@
 package gddoexp_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/rafaeljusto/gddoexp"
@@ -15,10 +17,43 @@ func TestError(t *testing.T) {
 		t.Errorf("expected “%s” and got “%s”", expected, msg)
 	}
 
-	err = gddoexp.NewError("path/to/project", gddoexp.ErrorCodeNonGithub, nil)
-	expected = "gddoexp: [path/to/project] not a Github project"
+	err = gddoexp.NewError("path/to/project", gddoexp.ErrorCodeUnsupportedHost, nil)
+	expected = "gddoexp: [path/to/project] hosting service not supported"
 
 	if msg := err.Error(); msg != expected {
 		t.Errorf("expected “%s” and got “%s”", expected, msg)
 	}
 }
+
+func TestErrorIs(t *testing.T) {
+	var err error = gddoexp.NewError("path/to/project", gddoexp.ErrorCodeGithubParse, io.ErrUnexpectedEOF)
+
+	if !errors.Is(err, gddoexp.ErrorCodeGithubParse) {
+		t.Error("expected error to match its code")
+	}
+
+	if errors.Is(err, gddoexp.ErrorCodeGithubNotFound) {
+		t.Error("unexpected match with a different code")
+	}
+
+	if !errors.Is(gddoexp.NewError("path/to/project", gddoexp.ErrorCodeUnsupportedHost, nil), gddoexp.ErrorCodeNonGithub) {
+		t.Error("expected the deprecated code to match the unsupported host code")
+	}
+
+	if !errors.Is(err, gddoexp.NewError("", gddoexp.ErrorCodeGithubParse, nil)) {
+		t.Error("expected error to match an error with the same code")
+	}
+
+	if errors.Is(err, gddoexp.NewError("path/to/other", gddoexp.ErrorCodeGithubParse, nil)) {
+		t.Error("unexpected match with an error from a different path")
+	}
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected error to match the low level error")
+	}
+
+	var gddoexpErr gddoexp.Error
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &gddoexpErr) || gddoexpErr.Path != "path/to/project" {
+		t.Error("expected to extract the error from a wrapped error")
+	}
+}
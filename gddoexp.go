@@ -1,28 +1,18 @@
 package gddoexp
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/golang/gddo/database"
-	"github.com/google/go-github/github"
 )
 
-// unused stores the time that an unmodified project is considered unused.
-const unused = 2 * 365 * 24 * time.Hour
-
-// commitsLimit is the maximum number of commits made in the fork so we could
-// identify as a fast fork.
-const commitsLimit = 2
-
-// commitsPeriod is the period after the fork creation date that we will
-// consider the commits a fast fork.
-const commitsPeriod = 7 * 24 * time.Hour
-
-// agents contains the number of concurrent go routines that will process
-// a list of packages
-const agents = 4
+// day is used to report periods in days.
+const day = 24 * time.Hour
 
 // gddoDB contains all used methods from Database type of
 // github.com/golang/gddo/database. This is useful for mocking and building
@@ -36,91 +26,65 @@ type gddoDB interface {
 type SuppressResponse struct {
 	Package  database.Package
 	Suppress bool
+	Verdict  Verdict
 	Cache    bool
-	Error    error
+
+	// Shared is the number of hosting service responses retrieved by other
+	// packages of the run that were used for this package, so each one is a
+	// request that wasn't sent. They aren't counted as cache hits.
+	Shared int
+
+	// Repository is the root path of the repository (e.g.
+	// github.com/owner/repo) retrieved for the package. It is empty when the
+	// repository wasn't retrieved.
+	Repository string
+
+	Error error
 }
 
 // ShouldSuppressPackage determinate if a package should be suppressed or not.
 // It's necessary to inform the GoDoc database to retrieve current stored
-// package information.
-func ShouldSuppressPackage(p database.Package, db gddoDB) (suppress, cache bool, err error) {
-	if !strings.HasPrefix(p.Path, "github.com") {
-		return false, true, NewError(p.Path, ErrorCodeNonGithub, nil)
-	}
-
-	count, err := db.ImporterCount(p.Path)
+// package information. The rules from DefaultRules are checked in order. The
+// Github credentials are optional.
+func ShouldSuppressPackage(ctx context.Context, p database.Package, db gddoDB, auth *GithubAuth) (suppress, cache bool, err error) {
+	checker, err := defaultChecker(auth)
 	if err != nil {
-		// as we didn't perform any request yet, we can return a cache hit to
-		// reuse the token
-		return false, true, NewError(p.Path, ErrorCodeRetrieveImportCounts, err)
+		return false, true, err
 	}
 
-	// don't suppress the package if there's a reference to it from other
-	// projects (let's avoid send a request to Github if we already no that we
-	// don't need to suppress it). We return cache hit as no request was made to
-	// Github API.
-	if count > 0 {
-		return false, true, nil
-	}
+	return checker.ShouldSuppressPackage(ctx, p, db)
+}
 
-	repository, cacheRepository, err := getGithubRepository(p.Path)
+// EvaluatePackage works like ShouldSuppressPackage, but instead of only
+// returning the decision, it returns the verdict with the rule that took the
+// decision and the evidences that it used.
+func EvaluatePackage(ctx context.Context, p database.Package, db gddoDB, auth *GithubAuth) (verdict Verdict, cache bool, err error) {
+	checker, err := defaultChecker(auth)
 	if err != nil {
-		return false, cacheRepository, err
+		return Verdict{}, true, err
 	}
 
-	// we only suppress the package if there's no reference to it from other
-	// projects (checked above) and if there's no updates in Github on the last
-	// 2 years
-	if time.Now().Sub(repository.UpdatedAt.Time) >= unused {
-		return true, cacheRepository, nil
-	}
-
-	// we will check if the package is a fork with a few commits for a pull
-	// request, if so we consider it a fast fork and is eligible to be
-	// suppressed
-	fastFork, cacheFastFork, err := isFastForkPackage(p, repository)
-	return fastFork, cacheRepository && cacheFastFork, err
+	return checker.EvaluatePackage(ctx, p, db)
 }
 
 // ShouldSuppressPackages determinate if a package should be suppressed or not,
 // but unlike ShouldSuppressPackage, it can process a list of packages
 // concurrently. It's necessary to inform the GoDoc database to retrieve
-// current stored package information.
-func ShouldSuppressPackages(packages []database.Package, db gddoDB) <-chan SuppressResponse {
-	out := make(chan SuppressResponse, agents)
-
-	go func() {
-		var wg sync.WaitGroup
-		wg.Add(agents)
-
-		in := make(chan database.Package)
-
-		for i := 0; i < agents; i++ {
-			go func() {
-				for p := range in {
-					suppress, cache, err := ShouldSuppressPackage(p, db)
-					out <- SuppressResponse{
-						Package:  p,
-						Suppress: suppress,
-						Cache:    cache,
-						Error:    err,
-					}
-				}
-
-				wg.Done()
-			}()
-		}
-
-		for _, pkg := range packages {
-			in <- pkg
+// current stored package information. The policy defines the rules thresholds
+// and the number of concurrent agents, and when it's invalid each package is
+// reported with the validation error. The Github credentials are optional.
+func ShouldSuppressPackages(ctx context.Context, packages []database.Package, db gddoDB, auth *GithubAuth, policy Policy) <-chan SuppressResponse {
+	checker, err := defaultChecker(auth)
+	if err != nil {
+		out := make(chan SuppressResponse, len(packages))
+		for _, p := range packages {
+			out <- SuppressResponse{Package: p, Cache: true, Error: err}
 		}
-
-		close(in)
-		wg.Wait()
 		close(out)
-	}()
+		return out
+	}
 
-	return out
+	return checker.withPolicy(policy).ShouldSuppressPackages(ctx, packages, db)
 }
 
 // FastForkResponse stores the information of a path verification on an
@@ -129,93 +93,734 @@ type FastForkResponse struct {
 	Path     string
 	FastFork bool
 	Cache    bool
-	Error    error
+
+	// Shared is the number of hosting service responses retrieved by other
+	// packages of the run that were used for this package, so each one is a
+	// request that wasn't sent. They aren't counted as cache hits.
+	Shared int
+
+	// Repository is the root path of the repository (e.g.
+	// github.com/owner/repo) retrieved for the package. It is empty when the
+	// repository wasn't retrieved.
+	Repository string
+
+	Error error
 }
 
 // IsFastForkPackage identifies if a package is a fork created only to make
-// small changes for a pull request.
-func IsFastForkPackage(p database.Package) (fastFork, cache bool, err error) {
-	repository, cacheRepository, err := getGithubRepository(p.Path)
+// small changes for a pull request. The Github credentials are optional.
+func IsFastForkPackage(ctx context.Context, p database.Package, auth *GithubAuth) (fastFork, cache bool, err error) {
+	checker, err := defaultChecker(auth)
 	if err != nil {
-		return false, cacheRepository, err
+		return false, true, err
+	}
+
+	return checker.IsFastForkPackage(ctx, p)
+}
+
+// AreFastForkPackages determinate if a package is a fast fork or not,
+// but unlike IsFastForkPackage, it can process a list of packages
+// concurrently. The policy defines the fast fork thresholds and the number of
+// concurrent agents, and when it's invalid each package is reported with the
+// validation error. The Github credentials are optional.
+func AreFastForkPackages(ctx context.Context, packages []database.Package, auth *GithubAuth, policy Policy) <-chan FastForkResponse {
+	checker, err := defaultChecker(auth)
+	if err != nil {
+		out := make(chan FastForkResponse, len(packages))
+		for _, p := range packages {
+			out <- FastForkResponse{Path: p.Path, Cache: true, Error: err}
+		}
+		close(out)
+		return out
 	}
 
-	fastFork, cache, err = isFastForkPackage(p, repository)
-	return fastFork, cache && cacheRepository, err
+	return checker.withPolicy(policy).AreFastForkPackages(ctx, packages)
 }
 
 // isFastForkPackage is the low level function that will actually check if
-// the package is a fast fork. It receives the repository information so we can
-// reuse it with the function ShouldSuppressPackage.
-func isFastForkPackage(p database.Package, repository *github.Repository) (fastFork, cache bool, err error) {
+// the package is a fast fork. It receives the subject so we can reuse the
+// repository information already retrieved by other rules. When the hosting
+// service can compare the fork with its parent, the fork is a fast fork if it
+// has at most the commits limit ahead of the parent, all of them sent in pull
+// requests that were merged or closed. Otherwise the commits made in the
+// period after the fork creation are checked. A fork with an open pull request
+// or with a deleted upstream is never a fast fork. It also returns the number
+// of commits ahead of the parent or found in the period after the fork
+// creation.
+func isFastForkPackage(s *Subject) (fastFork bool, commitCounts int, err error) {
+	repository, err := s.Repository()
+	if err != nil {
+		return false, 0, err
+	}
+
 	// if the repository is not a fork we don't need to check the commits
-	if !*repository.Fork {
-		return false, true, nil
+	if !repository.Fork {
+		return false, 0, nil
+	}
+
+	// without the upstream the fork may be the canonical copy now
+	network, err := s.Network()
+	if err != nil {
+		return false, 0, err
+	} else if network.UpstreamGone {
+		return false, 0, nil
 	}
 
-	commits, cache, err := getCommits(p.Path)
+	comparison, err := s.Comparison()
 	if err != nil {
-		return false, cache, err
+		return false, 0, err
+	}
+
+	if comparison != nil {
+		return isFastForkComparison(s, comparison)
 	}
 
-	forkLimitDate := repository.CreatedAt.Add(commitsPeriod)
-	commitCounts := 0
+	commits, err := s.Commits()
+	if err != nil {
+		return false, 0, err
+	}
+
+	forkLimitDate := repository.CreatedAt.Add(s.Policy.CommitsPeriod)
 	fastFork = true
 
 	for _, commit := range commits {
-		if commit.Commit.Author.Date.After(forkLimitDate) {
+		if commit.Date.After(forkLimitDate) {
 			fastFork = false
 			break
 		}
 
-		if commit.Commit.Author.Date.After(repository.CreatedAt.Time) {
+		if commit.Date.After(repository.CreatedAt) {
 			commitCounts++
 		}
 	}
 
-	if commitCounts > commitsLimit {
-		fastFork = false
+	if commitCounts > s.Policy.CommitsLimit || !fastFork {
+		return false, commitCounts, nil
 	}
 
-	return fastFork, cache, nil
+	// a fork with an open pull request is kept until the pull request is
+	// resolved, as well as a fork whose pull requests couldn't be checked
+	pullRequests, err := s.PullRequests()
+	if err != nil {
+		return false, commitCounts, err
+	}
+
+	state := pullRequestsState(pullRequests)
+	return state != PullRequestOpen && state != PullRequestUnknown, commitCounts, nil
 }
 
-// AreFastForkPackages determinate if a package is a fast fork or not,
-// but unlike IsFastForkPackage, it can process a list of packages
-// concurrently.
-func AreFastForkPackages(packages []database.Package) <-chan FastForkResponse {
-	out := make(chan FastForkResponse, agents)
-
-	go func() {
-		var wg sync.WaitGroup
-		wg.Add(agents)
-
-		in := make(chan database.Package)
-
-		for i := 0; i < agents; i++ {
-			go func() {
-				for p := range in {
-					fastFork, cache, err := IsFastForkPackage(p)
-					out <- FastForkResponse{
-						Path:     p.Path,
-						FastFork: fastFork,
-						Cache:    cache,
-						Error:    err,
-					}
-				}
-
-				wg.Done()
-			}()
+// isFastForkComparison checks if the fork is a fast fork using the comparison
+// with its parent, whatever the commit dates say. A fork without commits
+// ahead of the parent had all its changes merged (or never changed anything).
+func isFastForkComparison(s *Subject, comparison *Comparison) (bool, int, error) {
+	if comparison.AheadBy == 0 {
+		return true, 0, nil
+	}
+
+	if comparison.AheadBy > s.Policy.CommitsLimit {
+		return false, comparison.AheadBy, nil
+	}
+
+	pullRequests, err := s.PullRequests()
+	if err != nil {
+		return false, comparison.AheadBy, err
+	}
+
+	// commits that were never proposed to the parent are the fork's own work
+	if len(pullRequests) == 0 {
+		return false, comparison.AheadBy, nil
+	}
+
+	for _, pullRequest := range pullRequests {
+		if pullRequest.State == PullRequestOpen || pullRequest.State == PullRequestUnknown {
+			return false, comparison.AheadBy, nil
 		}
+	}
 
-		for _, pkg := range packages {
-			in <- pkg
+	return true, comparison.AheadBy, nil
+}
+
+// ImportersRule keeps the package when other projects import it. As it only
+// needs the GoDoc database, it should be the first rule, avoiding requests to
+// Github for packages that are in use. When the repository information was
+// already retrieved, like in the batch mode, the verdict also reports the
+// canonical path of a moved repository and flags an archived repository as
+// deprecated, without extra requests.
+type ImportersRule struct{}
+
+// Name identifies the rule.
+func (ImportersRule) Name() string {
+	return "importers"
+}
+
+// Check verifies if there's a reference to the package from other projects.
+func (ImportersRule) Check(s *Subject) (RuleResult, error) {
+	count, err := s.ImporterCount()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	evidence := Evidence{"importers": count}
+
+	if count == 0 {
+		return RuleResult{Reason: "no importers", Evidence: evidence}, nil
+	}
+
+	result := RuleResult{
+		Matched:  true,
+		Reason:   fmt.Sprintf("imported by %d projects", count),
+		Evidence: evidence,
+	}
+
+	repository := s.knownRepository()
+	if repository == nil {
+		return result, nil
+	}
+
+	// the known repository has a static root, so no request is sent
+	canonical, err := movedPath(s, repository)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	if canonical != "" {
+		result.Reason += fmt.Sprintf(", but moved to %s", canonical)
+		result.Evidence["full_name"] = repository.FullName
+		result.CanonicalPath = canonical
+	}
+
+	// the same flag of the archived rule
+	if repository.Archived && s.Policy.ArchivedWeight > 0 {
+		result.Reason += ", but archived"
+		result.Evidence["archived"] = true
+		result.Deprecated = true
+	}
+
+	return result, nil
+}
+
+// GoneRule suppresses the package when the repository was deleted (404 Not
+// Found) or blocked for legal reasons, like a DMCA takedown in Github (451
+// Unavailable For Legal Reasons). A renamed or transferred repository isn't
+// gone, as the hosting service redirects the request to the new location.
+type GoneRule struct{}
+
+// Name identifies the rule.
+func (GoneRule) Name() string {
+	return "gone"
+}
+
+// Check verifies if the repository still exists. A gone repository is only
+// suppressed when no other project imports the package.
+func (GoneRule) Check(s *Subject) (RuleResult, error) {
+	_, err := s.Repository()
+	if err == nil {
+		return RuleResult{Reason: "repository available"}, nil
+	}
+
+	status := goneStatus(err)
+	if status == 0 {
+		return RuleResult{}, err
+	}
+
+	reason := "repository deleted"
+	if status == http.StatusUnavailableForLegalReasons {
+		reason = "repository unavailable for legal reasons"
+	}
+
+	count, err := s.ImporterCount()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	evidence := Evidence{
+		"status":    status,
+		"importers": count,
+	}
+
+	if count > 0 {
+		return RuleResult{
+			Matched:  true,
+			Reason:   fmt.Sprintf("%s, but imported by %d projects", reason, count),
+			Evidence: evidence,
+		}, nil
+	}
+
+	return RuleResult{
+		Matched:  true,
+		Suppress: true,
+		Reason:   reason,
+		Evidence: evidence,
+	}, nil
+}
+
+// goneStatus returns the HTTP status code that the hosting service used to
+// report a repository that doesn't exist anymore, or 0 when the error has
+// another cause.
+func goneStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrorCodeGithubNotFound),
+		errors.Is(err, ErrorCodeBitbucketNotFound),
+		errors.Is(err, ErrorCodeGitlabNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrorCodeGithubUnavailable):
+		return http.StatusUnavailableForLegalReasons
+	}
+
+	return 0
+}
+
+// MovedRule suppresses the package when its path points to a repository that
+// was renamed or transferred. The hosting service redirects the requests to
+// the new location, so the repository full name doesn't match the path
+// anymore. The verdict reports the canonical import path, allowing GoDoc to
+// redirect instead of indexing a duplicate.
+type MovedRule struct{}
+
+// Name identifies the rule.
+func (MovedRule) Name() string {
+	return "moved"
+}
+
+// Check compares the repository full name with the package path.
+func (MovedRule) Check(s *Subject) (RuleResult, error) {
+	repository, err := s.Repository()
+	if goneStatus(err) != 0 {
+		// the gone rule decides about repositories that don't exist anymore,
+		// even when this rule is moved before it
+		return RuleResult{Reason: "repository gone"}, nil
+	} else if err != nil {
+		return RuleResult{}, err
+	}
+
+	// a vanity import path stays valid when the repository behind it moves
+	if s.RepositoryPath() != s.Package.Path {
+		return RuleResult{Reason: "vanity import path"}, nil
+	}
+
+	canonical, err := movedPath(s, repository)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	if canonical == "" {
+		return RuleResult{Reason: "canonical path"}, nil
+	}
+
+	return RuleResult{
+		Matched:  true,
+		Suppress: true,
+		Reason:   fmt.Sprintf("moved to %s", canonical),
+		Evidence: Evidence{
+			"full_name": repository.FullName,
+		},
+		CanonicalPath: canonical,
+	}, nil
+}
+
+// movedPath returns the canonical import path of the package when its
+// repository was renamed or transferred, or an empty string otherwise. The
+// hosting services ignore the case of the repository names, so a path that
+// only differs in the case isn't moved. Vanity import paths are never moved,
+// as they stay valid when the repository behind them moves.
+func movedPath(s *Subject, repository *Repository) (string, error) {
+	if s.RepositoryPath() != s.Package.Path {
+		return "", nil
+	}
+
+	root, err := s.root()
+	if err != nil {
+		return "", err
+	}
+	fullName := repository.FullName
+
+	// without the full name we can't tell if the repository was moved
+	if fullName == "" || strings.EqualFold(host(root)+"/"+fullName, root) {
+		return "", nil
+	}
+
+	return canonicalPath(s.Package.Path, root, fullName), nil
+}
+
+// ArchivedRule suppresses the package when its Github repository was archived
+// by the owner, an explicit sign that the project was abandoned. The archived
+// flag comes with the repository information, so the rule doesn't need extra
+// requests to Github. The policy archived weight defines how long an archived
+// repository must be idle before it is suppressed.
+//
+// Archived packages imported by other projects are kept and flagged as
+// deprecated. As the importers rule is checked first by default, it only flags
+// them when the repository was already retrieved, like in the batch mode with
+// GraphQL. Move this rule before it to flag them always, with a request for
+// each imported package:
+//
+//	rules.MoveBefore("archived", "importers")
+type ArchivedRule struct{}
+
+// Name identifies the rule.
+func (ArchivedRule) Name() string {
+	return "archived"
+}
+
+// Check verifies the archived flag of the repository.
+func (ArchivedRule) Check(s *Subject) (RuleResult, error) {
+	if s.Policy.ArchivedWeight <= 0 {
+		return RuleResult{Reason: "archived rule disabled"}, nil
+	}
+
+	repository, err := s.Repository()
+	if goneStatus(err) != 0 {
+		// the gone rule decides about repositories that don't exist anymore
+		return RuleResult{Reason: "repository gone"}, nil
+	} else if err != nil {
+		return RuleResult{}, err
+	}
+
+	if !repository.Archived {
+		return RuleResult{Reason: "not archived"}, nil
+	}
+
+	count, err := s.ImporterCount()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	threshold := time.Duration(float64(s.Policy.UnusedPeriod) * (1 - s.Policy.ArchivedWeight))
+	idle := time.Now().Sub(repository.UpdatedAt)
+	evidence := Evidence{
+		"importers":      count,
+		"updated_at":     repository.UpdatedAt,
+		"idle_days":      int(idle / day),
+		"threshold_days": int(threshold / day),
+		"weight":         s.Policy.ArchivedWeight,
+	}
+
+	if count > 0 {
+		return RuleResult{
+			Matched:    true,
+			Reason:     fmt.Sprintf("archived, but imported by %d projects", count),
+			Evidence:   evidence,
+			Deprecated: true,
+		}, nil
+	}
+
+	if idle >= threshold {
+		return RuleResult{
+			Matched:  true,
+			Suppress: true,
+			Reason:   fmt.Sprintf("archived and unused for %d days", idle/day),
+			Evidence: evidence,
+		}, nil
+	}
+
+	return RuleResult{Reason: "archived, but recently updated", Evidence: evidence}, nil
+}
+
+// UnusedRule suppresses the package when there was no activity in the
+// repository in the policy unused period (2 years by default). The activity
+// is measured by the policy activity signal: the last push, the last commit
+// in the default branch, the last commit in the package directory or the
+// last update of the repository. With the package granularity the commits
+// that touched the package directory are checked, so a dead subpackage inside
+// an active repository is suppressed.
+type UnusedRule struct{}
+
+// Name identifies the rule.
+func (UnusedRule) Name() string {
+	return "unused"
+}
+
+// Check verifies the last activity date of the package. The evidence
+// contains the activity signal that was used.
+func (r UnusedRule) Check(s *Subject) (RuleResult, error) {
+	signal := s.Policy.activity()
+	if signal == ActivityLastCommit || signal == ActivityPackageCommit {
+		return r.checkCommits(s, signal)
+	}
+
+	repository, err := s.Repository()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	activeAt := repository.UpdatedAt
+	if signal == ActivityPushedAt {
+		if repository.PushedAt.IsZero() {
+			// the hosting service doesn't report the pushes
+			signal = ActivityUpdatedAt
+		} else {
+			activeAt = repository.PushedAt
 		}
+	}
 
-		close(in)
-		wg.Wait()
-		close(out)
-	}()
+	idle := time.Now().Sub(activeAt)
+	evidence := Evidence{
+		"activity":       string(signal),
+		string(signal):   activeAt,
+		"idle_days":      int(idle / day),
+		"threshold_days": int(s.Policy.UnusedPeriod / day),
+	}
+
+	if idle >= s.Policy.UnusedPeriod {
+		return RuleResult{
+			Matched:  true,
+			Suppress: true,
+			Reason:   fmt.Sprintf("unused for %d days", idle/day),
+			Evidence: evidence,
+		}, nil
+	}
+
+	return RuleResult{Reason: "recently updated", Evidence: evidence}, nil
+}
+
+// checkCommits verifies if there's a commit in the default branch or in the
+// package directory made in the unused period.
+func (UnusedRule) checkCommits(s *Subject, signal ActivitySignal) (RuleResult, error) {
+	place, commits := "default branch", s.Commits
+	if signal == ActivityPackageCommit {
+		place, commits = "package directory", s.PackageCommits
+	}
+
+	list, err := commits()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	evidence := Evidence{
+		"activity":       string(signal),
+		"commits":        len(list),
+		"threshold_days": int(s.Policy.UnusedPeriod / day),
+	}
+
+	if s.Policy.Granularity == GranularityPackage {
+		evidence["granularity"] = string(GranularityPackage)
+	}
+
+	if len(list) == 0 {
+		return RuleResult{
+			Matched:  true,
+			Suppress: true,
+			Reason: fmt.Sprintf("no commits in the %s for %d days",
+				place, s.Policy.UnusedPeriod/day),
+			Evidence: evidence,
+		}, nil
+	}
+
+	var lastCommit time.Time
+	for _, commit := range list {
+		if commit.Date.After(lastCommit) {
+			lastCommit = commit.Date
+		}
+	}
+	evidence["last_commit_at"] = lastCommit
+
+	return RuleResult{Reason: "recently updated " + place, Evidence: evidence}, nil
+}
+
+// FastForkRule suppresses the package when it's a fork created only to make
+// small changes for a pull request. Forks with an open pull request in the
+// parent repository are kept until the pull request is resolved, forks with
+// branches that couldn't be checked for pull requests are kept, and forks of
+// deleted repositories are kept as they may be the canonical copy now.
+type FastForkRule struct{}
+
+// Name identifies the rule.
+func (FastForkRule) Name() string {
+	return "fast-fork"
+}
+
+// Check verifies if the package is a fast fork.
+func (FastForkRule) Check(s *Subject) (RuleResult, error) {
+	fastFork, commits, err := isFastForkPackage(s)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	evidence := Evidence{
+		"commits":       commits,
+		"commits_limit": s.Policy.CommitsLimit,
+	}
+
+	// the fork network and the comparison were already retrieved by
+	// isFastForkPackage
+	network, _ := s.Network()
+	if network != nil {
+		evidence["fork_depth"] = network.Depth
+		if network.Root != "" {
+			evidence["network_root"] = network.Root
+		}
+
+		if network.UpstreamGone {
+			evidence["upstream_gone"] = true
+			return RuleResult{
+				Matched:  true,
+				Reason:   fmt.Sprintf("fork of the deleted repository %s, it may be the canonical copy now", network.Root),
+				Evidence: evidence,
+			}, nil
+		}
+	}
+
+	comparison, _ := s.Comparison()
+	if comparison != nil {
+		evidence["ahead_by"] = comparison.AheadBy
+		evidence["behind_by"] = comparison.BehindBy
+	} else {
+		evidence["period_days"] = int(s.Policy.CommitsPeriod / day)
+		if s.Policy.Granularity == GranularityPackage {
+			evidence["granularity"] = string(GranularityPackage)
+		}
+	}
+
+	// the pull requests are only correlated with a fork that is otherwise a
+	// fast fork, or when they already decided that it isn't one, so no
+	// request is sent for the forks ruled out by their commits
+	var pullRequests []PullRequest
+	if fastFork || s.pullRequestsLoaded {
+		pullRequests, err = s.PullRequests()
+		if err != nil {
+			return RuleResult{}, err
+		}
+	}
+
+	// the branches that couldn't be checked aren't pull requests
+	var sent int
+	for _, pullRequest := range pullRequests {
+		if pullRequest.State != PullRequestUnknown {
+			sent++
+		}
+	}
+
+	state := pullRequestsState(pullRequests)
+	if sent > 0 {
+		evidence["pull_requests"] = sent
+	}
+
+	switch state {
+	case PullRequestOpen:
+		return RuleResult{
+			Matched:     true,
+			Reason:      "fork with an open pull request, kept until it is resolved",
+			Evidence:    evidence,
+			PullRequest: state,
+		}, nil
+
+	case PullRequestUnknown:
+		return RuleResult{
+			Matched:     true,
+			Reason:      "fork with too many branches to check its pull requests",
+			Evidence:    evidence,
+			PullRequest: state,
+		}, nil
+	}
+
+	if !fastFork {
+		return RuleResult{Reason: "not a fast fork", Evidence: evidence, PullRequest: state}, nil
+	}
+
+	var reason string
+	switch {
+	case comparison == nil:
+		reason = fmt.Sprintf("fast fork with %d commits in %d days after the fork",
+			commits, s.Policy.CommitsPeriod/day)
+	case comparison.AheadBy == 0:
+		reason = "fast fork without commits ahead of the parent"
+	default:
+		reason = fmt.Sprintf("fast fork with %d commits ahead of the parent, all in merged or closed pull requests",
+			comparison.AheadBy)
+	}
+
+	// the fork existed only to send the pull requests, that were resolved
+	if state != "" {
+		reason += fmt.Sprintf(", disposable after its pull request was %s", state)
+		evidence["disposable"] = true
+	}
+
+	return RuleResult{
+		Matched:     true,
+		Suppress:    true,
+		Reason:      reason,
+		Evidence:    evidence,
+		PullRequest: state,
+	}, nil
+}
+
+// SuccessorRule keeps a fork that became the maintained successor of its
+// parent: the parent wasn't updated in the policy unused period, while the
+// fork was, and the fork has more importers or more stars than the parent.
+// The verdict reports the parent import path, so GoDoc can rank the fork
+// higher and suppress the dead parent. The rule isn't in the default rules,
+// and it needs the GoDoc database to compare the importers. For gopkg.in and
+// vanity import paths only the stars are compared, as the import path of the
+// parent in the same namespace is unknown.
+type SuccessorRule struct{}
+
+// Name identifies the rule.
+func (SuccessorRule) Name() string {
+	return "successor"
+}
+
+// Check compares the activity, the stars and the importers of the fork and
+// its parent.
+func (SuccessorRule) Check(s *Subject) (RuleResult, error) {
+	repository, err := s.Repository()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	parent, err := s.Parent()
+	if err != nil {
+		return RuleResult{}, err
+	} else if parent == nil {
+		return RuleResult{Reason: "not a fork of an available repository"}, nil
+	}
+
+	importers, err := s.ImporterCount()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	root, err := s.root()
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	activeAt, parentActiveAt := repository.activeAt(), parent.activeAt()
+	evidence := Evidence{
+		"active_at":        activeAt,
+		"parent_active_at": parentActiveAt,
+		"stars":            repository.Stars,
+		"parent_stars":     parent.Stars,
+		"importers":        importers,
+		"threshold_days":   int(s.Policy.UnusedPeriod / day),
+	}
+
+	// GoDoc indexes the packages by their import paths, so the importers of
+	// the parent are only known when the package uses the hosting service path
+	parentPath := canonicalPath(s.resolved.path, root, parent.FullName)
+	morePopular := repository.Stars > parent.Stars
+	if s.RepositoryPath() == s.Package.Path {
+		parentImporters, err := s.db.ImporterCount(parentPath)
+		if err != nil {
+			return RuleResult{}, NewError(parentPath, ErrorCodeRetrieveImportCounts, err)
+		}
+
+		evidence["parent_importers"] = parentImporters
+		morePopular = morePopular || importers > parentImporters
+	}
+
+	limit := time.Now().Add(-s.Policy.UnusedPeriod)
+	if parentActiveAt.After(limit) || !activeAt.After(limit) {
+		return RuleResult{Reason: "fork and parent with the same activity", Evidence: evidence}, nil
+	}
+
+	if !morePopular {
+		return RuleResult{Reason: "fork less popular than the parent", Evidence: evidence}, nil
+	}
 
-	return out
+	return RuleResult{
+		Matched:    true,
+		Reason:     fmt.Sprintf("fork %s supersedes parent %s", repository.FullName, parent.FullName),
+		Evidence:   evidence,
+		Supersedes: parentPath,
+	}, nil
 }
@@ -2,13 +2,17 @@ package gddoexp_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,6 +26,7 @@ func TestShouldSuppressPackage(t *testing.T) {
 	data := []struct {
 		description   string
 		path          string
+		auth          *gddoexp.GithubAuth
 		db            databaseMock
 		httpClient    httpClientMock
 		expected      bool
@@ -94,6 +99,7 @@ func TestShouldSuppressPackage(t *testing.T) {
 		},
 		{
 			description: "it should suppress a package (authenticated)",
+			auth:        &gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123"},
 			path:        "github.com/rafaeljusto/gddoexp",
 			db: databaseMock{
 				importerCountMock: func(path string) (int, error) {
@@ -213,15 +219,15 @@ func TestShouldSuppressPackage(t *testing.T) {
 			expectedError: gddoexp.NewError("github.com/rafaeljusto/gddoexp", gddoexp.ErrorCodeRetrieveImportCounts, fmt.Errorf("i'm a crazy error")),
 		},
 		{
-			description: "it should fail when it's not a Github project",
-			path:        "bitbucket.org/rafaeljusto/gddoexp",
+			description: "it should fail when it's not from a supported hosting service",
+			path:        "code.google.com/p/gddoexp",
 			db: databaseMock{
 				importerCountMock: func(path string) (int, error) {
 					return 0, nil
 				},
 			},
 			expectedCache: true,
-			expectedError: gddoexp.NewError("bitbucket.org/rafaeljusto/gddoexp", gddoexp.ErrorCodeNonGithub, nil),
+			expectedError: gddoexp.NewError("code.google.com/p/gddoexp", gddoexp.ErrorCodeUnsupportedHost, nil),
 		},
 		{
 			description: "it should fail when there's a HTTP problem with Github API",
@@ -307,7 +313,7 @@ func TestShouldSuppressPackage(t *testing.T) {
 			expected: true,
 		},
 		{
-			description: "it should fail when the HTTP status code from Github API is 404 Not Found",
+			description: "it should suppress a package when the HTTP status code from Github API is 404 Not Found",
 			path:        "github.com/rafaeljusto/gddoexp",
 			db: databaseMock{
 				importerCountMock: func(path string) (int, error) {
@@ -321,7 +327,7 @@ func TestShouldSuppressPackage(t *testing.T) {
 					}, nil
 				},
 			},
-			expectedError: gddoexp.NewError("github.com/rafaeljusto/gddoexp", gddoexp.ErrorCodeGithubNotFound, nil),
+			expected: true,
 		},
 		{
 			description: "it should fail when the HTTP status code from Github API isn't valid",
@@ -360,27 +366,14 @@ func TestShouldSuppressPackage(t *testing.T) {
 		},
 	}
 
-	httpClientBkp := gddoexp.GHClient
-	defer func() {
-		gddoexp.GHClient = httpClientBkp
-	}()
-
-	isCacheResponseBkp := gddoexp.IsCacheResponse
-	defer func() {
-		gddoexp.IsCacheResponse = isCacheResponseBkp
-	}()
-	gddoexp.IsCacheResponse = func(r *http.Response) bool {
-		return r.Header.Get("Cache") == "1"
-	}
-
 	for i, item := range data {
-		gddoexp.GHClient = item.httpClient
+		checker := newChecker(t, item.httpClient, item.auth)
 
 		p := database.Package{
 			Path: item.path,
 		}
 
-		suppress, cache, err := gddoexp.ShouldSuppressPackage(p, item.db)
+		suppress, cache, err := checker.ShouldSuppressPackage(context.Background(), p, item.db)
 
 		if suppress != item.expected {
 			if item.expected {
@@ -398,7 +391,7 @@ func TestShouldSuppressPackage(t *testing.T) {
 			}
 		}
 
-		if !reflect.DeepEqual(item.expectedError, err) {
+		if !equalError(item.expectedError, err) {
 			t.Errorf("[%d] %s: expected error to be “%v” and got “%v”", i, item.description, item.expectedError, err)
 		}
 	}
@@ -408,6 +401,7 @@ func TestShouldSuppressPackages(t *testing.T) {
 	data := []struct {
 		description string
 		packages    []database.Package
+		auth        *gddoexp.GithubAuth
 		db          databaseMock
 		httpClient  httpClientMock
 		expected    []gddoexp.SuppressResponse
@@ -442,29 +436,35 @@ func TestShouldSuppressPackages(t *testing.T) {
 			},
 			expected: []gddoexp.SuppressResponse{
 				{
-					Package:  database.Package{Path: "github.com/docker/docker"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/docker/docker"},
+					Suppress:   true,
+					Repository: "github.com/docker/docker",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/gddo"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/golang/gddo"},
+					Suppress:   true,
+					Repository: "github.com/golang/gddo",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/go"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/golang/go"},
+					Suppress:   true,
+					Repository: "github.com/golang/go",
 				},
 				{
-					Package:  database.Package{Path: "github.com/miekg/dns"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/miekg/dns"},
+					Suppress:   true,
+					Repository: "github.com/miekg/dns",
 				},
 				{
-					Package:  database.Package{Path: "github.com/rafaeljusto/gddoexp"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/rafaeljusto/gddoexp"},
+					Suppress:   true,
+					Repository: "github.com/rafaeljusto/gddoexp",
 				},
 			},
 		},
 		{
 			description: "it should suppress all the packages (authenticated)",
+			auth:        &gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123"},
 			packages: []database.Package{
 				{Path: "github.com/rafaeljusto/gddoexp"},
 				{Path: "github.com/golang/gddo"},
@@ -499,24 +499,29 @@ func TestShouldSuppressPackages(t *testing.T) {
 			},
 			expected: []gddoexp.SuppressResponse{
 				{
-					Package:  database.Package{Path: "github.com/docker/docker"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/docker/docker"},
+					Suppress:   true,
+					Repository: "github.com/docker/docker",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/gddo"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/golang/gddo"},
+					Suppress:   true,
+					Repository: "github.com/golang/gddo",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/go"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/golang/go"},
+					Suppress:   true,
+					Repository: "github.com/golang/go",
 				},
 				{
-					Package:  database.Package{Path: "github.com/miekg/dns"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/miekg/dns"},
+					Suppress:   true,
+					Repository: "github.com/miekg/dns",
 				},
 				{
-					Package:  database.Package{Path: "github.com/rafaeljusto/gddoexp"},
-					Suppress: true,
+					Package:    database.Package{Path: "github.com/rafaeljusto/gddoexp"},
+					Suppress:   true,
+					Repository: "github.com/rafaeljusto/gddoexp",
 				},
 			},
 		},
@@ -553,55 +558,52 @@ func TestShouldSuppressPackages(t *testing.T) {
 			},
 			expected: []gddoexp.SuppressResponse{
 				{
-					Package:  database.Package{Path: "github.com/docker/docker"},
-					Suppress: true,
-					Cache:    true,
+					Package:    database.Package{Path: "github.com/docker/docker"},
+					Suppress:   true,
+					Cache:      true,
+					Repository: "github.com/docker/docker",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/gddo"},
-					Suppress: true,
-					Cache:    true,
+					Package:    database.Package{Path: "github.com/golang/gddo"},
+					Suppress:   true,
+					Cache:      true,
+					Repository: "github.com/golang/gddo",
 				},
 				{
-					Package:  database.Package{Path: "github.com/golang/go"},
-					Suppress: true,
-					Cache:    true,
+					Package:    database.Package{Path: "github.com/golang/go"},
+					Suppress:   true,
+					Cache:      true,
+					Repository: "github.com/golang/go",
 				},
 				{
-					Package:  database.Package{Path: "github.com/miekg/dns"},
-					Suppress: true,
-					Cache:    true,
+					Package:    database.Package{Path: "github.com/miekg/dns"},
+					Suppress:   true,
+					Cache:      true,
+					Repository: "github.com/miekg/dns",
 				},
 				{
-					Package:  database.Package{Path: "github.com/rafaeljusto/gddoexp"},
-					Suppress: true,
-					Cache:    true,
+					Package:    database.Package{Path: "github.com/rafaeljusto/gddoexp"},
+					Suppress:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/gddoexp",
 				},
 			},
 		},
 	}
 
-	httpClientBkp := gddoexp.GHClient
-	defer func() {
-		gddoexp.GHClient = httpClientBkp
-	}()
-
-	isCacheResponseBkp := gddoexp.IsCacheResponse
-	defer func() {
-		gddoexp.IsCacheResponse = isCacheResponseBkp
-	}()
-	gddoexp.IsCacheResponse = func(r *http.Response) bool {
-		return r.Header.Get("Cache") == "1"
-	}
-
 	for i, item := range data {
-		gddoexp.GHClient = item.httpClient
+		checker := newChecker(t, item.httpClient, item.auth)
 
 		var responses []gddoexp.SuppressResponse
-		for response := range gddoexp.ShouldSuppressPackages(item.packages, item.db) {
+		for response := range checker.ShouldSuppressPackages(context.Background(), item.packages, item.db) {
 			responses = append(responses, response)
 		}
 
+		// the verdict details are checked by the rule set tests
+		for j := range responses {
+			responses[j].Verdict = gddoexp.Verdict{}
+		}
+
 		sort.Sort(bySuppressResponsePath(responses))
 		if !reflect.DeepEqual(item.expected, responses) {
 			t.Errorf("[%d] %s: mismatch responses.\n%v", i, item.description, diff(item.expected, responses))
@@ -609,10 +611,132 @@ func TestShouldSuppressPackages(t *testing.T) {
 	}
 }
 
+func TestShouldSuppressPackagesInvalidPolicy(t *testing.T) {
+	checker := newChecker(t, httpClientMock{
+		getMock: func(url string) (*http.Response, error) {
+			t.Errorf("unexpected request to “%s” with an invalid policy", url)
+			return &http.Response{StatusCode: http.StatusInternalServerError}, nil
+		},
+	}, nil)
+
+	// a zero unused period would suppress every package without importers
+	checker.Policy = gddoexp.Policy{}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	packages := []database.Package{
+		{Path: "github.com/rafaeljusto/gddoexp"},
+		{Path: "github.com/rafaeljusto/dns"},
+	}
+
+	var responses int
+	for response := range checker.ShouldSuppressPackages(context.Background(), packages, db) {
+		responses++
+
+		if response.Error == nil || !strings.Contains(response.Error.Error(), "invalid policy") {
+			t.Errorf("expected invalid policy error for package “%s” and got “%v”", response.Package.Path, response.Error)
+		}
+
+		if response.Suppress {
+			t.Errorf("unexpected suppression of package “%s” with an invalid policy", response.Package.Path)
+		}
+	}
+
+	if responses != len(packages) {
+		t.Errorf("expected %d responses and got %d", len(packages), responses)
+	}
+
+	for response := range checker.AreFastForkPackages(context.Background(), packages) {
+		if response.Error == nil || response.FastFork {
+			t.Errorf("expected invalid policy error for package “%s” and got “%v”", response.Path, response.Error)
+		}
+	}
+}
+
+func TestShouldSuppressPackagesShared(t *testing.T) {
+	var mutex sync.Mutex
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/rafaeljusto/gddoexp" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+
+		fmt.Fprintf(w, `{"full_name": "rafaeljusto/gddoexp", "updated_at": "%s"}`,
+			time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	packages := []database.Package{
+		{Path: "github.com/rafaeljusto/gddoexp"},
+		{Path: "github.com/rafaeljusto/gddoexp/cmd/gddoexp"},
+		{Path: "github.com/rafaeljusto/gddoexp/cmd/gddofork"},
+		{Path: "github.com/rafaeljusto/gddoexp/cmd/gddoscore"},
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	var cache, shared int
+	repositories := make(map[string]int)
+	for response := range checker.ShouldSuppressPackages(context.Background(), packages, db) {
+		if response.Error != nil {
+			t.Errorf("unexpected error “%v”", response.Error)
+		}
+
+		if !response.Suppress {
+			t.Errorf("expected package “%s” to be suppressed", response.Package.Path)
+		}
+
+		repositories[response.Repository]++
+		shared += response.Shared
+
+		if response.Cache {
+			cache++
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("expected 1 request to Github and got %d", requests)
+	}
+
+	if repositories["github.com/rafaeljusto/gddoexp"] != len(packages) {
+		t.Errorf("expected the repository to be retrieved for %d packages and got %d", len(packages), repositories["github.com/rafaeljusto/gddoexp"])
+	}
+
+	// only the package that retrieved the repository sent a request
+	if shared != len(packages)-1 {
+		t.Errorf("expected %d shared responses and got %d", len(packages)-1, shared)
+	}
+
+	// the shared responses keep the cache status of the request
+	if cache != 0 {
+		t.Errorf("expected no cache hits and got %d", cache)
+	}
+}
+
 func TestIsFastForkPackage(t *testing.T) {
 	data := []struct {
 		description   string
 		path          string
+		auth          *gddoexp.GithubAuth
 		httpClient    httpClientMock
 		expected      bool
 		expectedCache bool
@@ -657,6 +781,7 @@ func TestIsFastForkPackage(t *testing.T) {
 		},
 		{
 			description: "it should detect a fast fork package (authenticated)",
+			auth:        &gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123"},
 			path:        "github.com/rafaeljusto/dns",
 			httpClient: httpClientMock{
 				getMock: func(url string) (*http.Response, error) {
@@ -840,34 +965,21 @@ func TestIsFastForkPackage(t *testing.T) {
 			expected: false,
 		},
 		{
-			description:   "it should fail when it's not a Github project",
-			path:          "bitbucket.org/rafaeljusto/gddoexp",
+			description:   "it should fail when it's not from a supported hosting service",
+			path:          "code.google.com/p/gddoexp",
 			expectedCache: true,
-			expectedError: gddoexp.NewError("bitbucket.org/rafaeljusto/gddoexp", gddoexp.ErrorCodeNonGithub, nil),
+			expectedError: gddoexp.NewError("code.google.com/p/gddoexp", gddoexp.ErrorCodeUnsupportedHost, nil),
 		},
 	}
 
-	httpClientBkp := gddoexp.GHClient
-	defer func() {
-		gddoexp.GHClient = httpClientBkp
-	}()
-
-	isCacheResponseBkp := gddoexp.IsCacheResponse
-	defer func() {
-		gddoexp.IsCacheResponse = isCacheResponseBkp
-	}()
-	gddoexp.IsCacheResponse = func(r *http.Response) bool {
-		return r.Header.Get("Cache") == "1"
-	}
-
 	for i, item := range data {
-		gddoexp.GHClient = item.httpClient
+		checker := newChecker(t, item.httpClient, item.auth)
 
 		p := database.Package{
 			Path: item.path,
 		}
 
-		fastFork, cache, err := gddoexp.IsFastForkPackage(p)
+		fastFork, cache, err := checker.IsFastForkPackage(context.Background(), p)
 
 		if fastFork != item.expected {
 			if item.expected {
@@ -885,7 +997,7 @@ func TestIsFastForkPackage(t *testing.T) {
 			}
 		}
 
-		if !reflect.DeepEqual(item.expectedError, err) {
+		if !equalError(item.expectedError, err) {
 			t.Errorf("[%d] %s: expected error to be “%v” and got “%v”", i, item.description, item.expectedError, err)
 		}
 	}
@@ -895,6 +1007,7 @@ func TestAreFastForkPackages(t *testing.T) {
 	data := []struct {
 		description string
 		packages    []database.Package
+		auth        *gddoexp.GithubAuth
 		httpClient  httpClientMock
 		expected    []gddoexp.FastForkResponse
 	}{
@@ -935,29 +1048,35 @@ func TestAreFastForkPackages(t *testing.T) {
 			},
 			expected: []gddoexp.FastForkResponse{
 				{
-					Path:     "github.com/rafaeljusto/dns",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/dns",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/dns",
 				},
 				{
-					Path:     "github.com/rafaeljusto/go-testdb",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/go-testdb",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/go-testdb",
 				},
 				{
-					Path:     "github.com/rafaeljusto/handy",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/handy",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/handy",
 				},
 				{
-					Path:     "github.com/rafaeljusto/mysql",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/mysql",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/mysql",
 				},
 				{
-					Path:     "github.com/rafaeljusto/schema",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/schema",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/schema",
 				},
 			},
 		},
 		{
 			description: "it should detect that all packages are fast fork (authenticated)",
+			auth:        &gddoexp.GithubAuth{ID: "exampleuser", Secret: "abc123"},
 			packages: []database.Package{
 				{Path: "github.com/rafaeljusto/dns"},
 				{Path: "github.com/rafaeljusto/go-testdb"},
@@ -1001,24 +1120,29 @@ func TestAreFastForkPackages(t *testing.T) {
 			},
 			expected: []gddoexp.FastForkResponse{
 				{
-					Path:     "github.com/rafaeljusto/dns",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/dns",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/dns",
 				},
 				{
-					Path:     "github.com/rafaeljusto/go-testdb",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/go-testdb",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/go-testdb",
 				},
 				{
-					Path:     "github.com/rafaeljusto/handy",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/handy",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/handy",
 				},
 				{
-					Path:     "github.com/rafaeljusto/mysql",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/mysql",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/mysql",
 				},
 				{
-					Path:     "github.com/rafaeljusto/schema",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/schema",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/schema",
 				},
 			},
 		},
@@ -1065,29 +1189,34 @@ func TestAreFastForkPackages(t *testing.T) {
 			},
 			expected: []gddoexp.FastForkResponse{
 				{
-					Path:     "github.com/rafaeljusto/dns",
-					FastFork: true,
-					Cache:    true,
+					Path:       "github.com/rafaeljusto/dns",
+					FastFork:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/dns",
 				},
 				{
-					Path:     "github.com/rafaeljusto/go-testdb",
-					FastFork: true,
-					Cache:    true,
+					Path:       "github.com/rafaeljusto/go-testdb",
+					FastFork:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/go-testdb",
 				},
 				{
-					Path:     "github.com/rafaeljusto/handy",
-					FastFork: true,
-					Cache:    true,
+					Path:       "github.com/rafaeljusto/handy",
+					FastFork:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/handy",
 				},
 				{
-					Path:     "github.com/rafaeljusto/mysql",
-					FastFork: true,
-					Cache:    true,
+					Path:       "github.com/rafaeljusto/mysql",
+					FastFork:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/mysql",
 				},
 				{
-					Path:     "github.com/rafaeljusto/schema",
-					FastFork: true,
-					Cache:    true,
+					Path:       "github.com/rafaeljusto/schema",
+					FastFork:   true,
+					Cache:      true,
+					Repository: "github.com/rafaeljusto/schema",
 				},
 			},
 		},
@@ -1131,47 +1260,39 @@ func TestAreFastForkPackages(t *testing.T) {
 			},
 			expected: []gddoexp.FastForkResponse{
 				{
-					Path:     "github.com/rafaeljusto/dns",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/dns",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/dns",
 				},
 				{
-					Path:     "github.com/rafaeljusto/go-testdb",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/go-testdb",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/go-testdb",
 				},
 				{
-					Path:     "github.com/rafaeljusto/handy",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/handy",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/handy",
 				},
 				{
-					Path:     "github.com/rafaeljusto/mysql",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/mysql",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/mysql",
 				},
 				{
-					Path:     "github.com/rafaeljusto/schema",
-					FastFork: true,
+					Path:       "github.com/rafaeljusto/schema",
+					FastFork:   true,
+					Repository: "github.com/rafaeljusto/schema",
 				},
 			},
 		},
 	}
 
-	httpClientBkp := gddoexp.GHClient
-	defer func() {
-		gddoexp.GHClient = httpClientBkp
-	}()
-
-	isCacheResponseBkp := gddoexp.IsCacheResponse
-	defer func() {
-		gddoexp.IsCacheResponse = isCacheResponseBkp
-	}()
-	gddoexp.IsCacheResponse = func(r *http.Response) bool {
-		return r.Header.Get("Cache") == "1"
-	}
-
 	for i, item := range data {
-		gddoexp.GHClient = item.httpClient
+		checker := newChecker(t, item.httpClient, item.auth)
 
 		var responses []gddoexp.FastForkResponse
-		for response := range gddoexp.AreFastForkPackages(item.packages) {
+		for response := range checker.AreFastForkPackages(context.Background(), item.packages) {
 			responses = append(responses, response)
 		}
 
@@ -1194,8 +1315,54 @@ type httpClientMock struct {
 	getMock func(string) (*http.Response, error)
 }
 
-func (h httpClientMock) Get(url string) (*http.Response, error) {
-	return h.getMock(url)
+// RoundTrip sends the request URL to the mock function. The commits filters
+// are removed from the URL, so the mock only needs to check the endpoint and
+// the credentials.
+func (h httpClientMock) RoundTrip(r *http.Request) (*http.Response, error) {
+	u := *r.URL
+	query := u.Query()
+	for _, filter := range []string{"path", "since", "until"} {
+		query.Del(filter)
+	}
+	u.RawQuery = query.Encode()
+
+	response, err := h.getMock(u.String())
+	if response != nil {
+		response.Request = r
+		if response.Header == nil {
+			response.Header = make(http.Header)
+		}
+		if response.Body == nil {
+			response.Body = ioutil.NopCloser(bytes.NewReader(nil))
+		}
+	}
+
+	return response, err
+}
+
+// newChecker builds a checker that sends all requests to the HTTP client mock
+// with the given credentials, and that detects cache hits from the "Cache"
+// HTTP header.
+func newChecker(t *testing.T, httpClient httpClientMock, auth *gddoexp.GithubAuth) *gddoexp.Checker {
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		HTTPClient: &http.Client{Transport: httpClient},
+		Auth:       auth,
+		IsCacheResponse: func(r *http.Response) bool {
+			return r.Header.Get("Cache") == "1"
+		},
+		Retry: gddoexp.RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     10 * time.Millisecond,
+			Multiplier:      2,
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	return checker
 }
 
 func diff(a, b interface{}) []difflib.DiffRecord {
@@ -1216,3 +1383,25 @@ type byFastForkResponsePath []gddoexp.FastForkResponse
 func (b byFastForkResponsePath) Len() int           { return len(b) }
 func (b byFastForkResponsePath) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b byFastForkResponsePath) Less(i, j int) bool { return b[i].Path < b[j].Path }
+
+// equalError compares the expected error with the returned one. For errors of
+// this library, when the expected error has no details, only the path and the
+// code are compared, as the details store the low level error from the Github
+// client.
+func equalError(expected, err error) bool {
+	expectedErr, ok := expected.(gddoexp.Error)
+	if !ok {
+		return reflect.DeepEqual(expected, err)
+	}
+
+	if !errors.Is(err, expectedErr) {
+		return false
+	}
+
+	if expectedErr.Details == nil {
+		return true
+	}
+
+	details := errors.Unwrap(err)
+	return details != nil && details.Error() == expectedErr.Details.Error()
+}
@@ -1,78 +1,390 @@
 package gddoexp
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
-	"os"
-	"path"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
-	"github.com/gregjones/httpcache"
-	"github.com/gregjones/httpcache/diskcache"
 )
 
-// Use a global github client for concurrent request.
-var GHClient *github.Client
+// githubProvider retrieves the repository information from Github API, using
+// the checker Github client.
+type githubProvider struct {
+	checker *Checker
+}
 
-// IsCacheResponse detects if a HTTP response was retrieved from cache or
-// not.
-var IsCacheResponse func(*http.Response) bool
+// Match checks if the path has the "github.com/owner/repo" layout.
+func (githubProvider) Match(path string) bool {
+	return matchRepository(path)
+}
 
-func init() {
-	t := &github.UnauthenticatedRateLimitedTransport{
-		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
-		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
-		Transport: httpcache.NewTransport(
-			diskcache.New(path.Join(os.Getenv("HOME"), ".gddoexp")),
-		),
-	}
-	GHClient = github.NewClient(t.Client())
+// Repository retrieves the repository information from Github.
+func (p githubProvider) Repository(ctx context.Context, path string) (*Repository, bool, error) {
+	return p.checker.getGithubRepository(ctx, path)
+}
+
+// CommitsSince retrieves the commits from a Github repository made after the
+// since date.
+func (p githubProvider) CommitsSince(ctx context.Context, path string, since time.Time) ([]Commit, bool, error) {
+	return p.checker.getGithubCommits(ctx, path, "", since)
+}
+
+// CommitsSinceRef retrieves the commits from a branch or tag of a Github
+// repository made after the since date.
+func (p githubProvider) CommitsSinceRef(ctx context.Context, path, ref string, since time.Time) ([]Commit, bool, error) {
+	return p.checker.getGithubCommits(ctx, path, ref, since)
 }
 
-func getGithubRepository(path string) (*github.Repository, bool, error) {
+// CompareWithParent compares a branch of a Github fork with the default branch
+// of its parent.
+func (p githubProvider) CompareWithParent(ctx context.Context, repository *Repository, ref string) (*Comparison, bool, error) {
+	return p.checker.compareGithubFork(ctx, repository, ref)
+}
+
+// PullRequests retrieves the pull requests sent from the branches of a Github
+// fork to its parent.
+func (p githubProvider) PullRequests(ctx context.Context, repository *Repository, ref string) ([]PullRequest, bool, error) {
+	return p.checker.getGithubPullRequests(ctx, repository, ref)
+}
+
+// getGithubRepository retrieves the repository information from Github. This
+// function also returns if the response was retrieved from a local cache.
+func (c *Checker) getGithubRepository(ctx context.Context, path string) (*Repository, bool, error) {
 	owner, repo := parse(path)
-	repository, response, err := GHClient.Repositories.Get(owner, repo)
-	if err, ok := err.(*github.RateLimitError); ok {
-		t := err.Rate.Reset
-		time.Sleep(t.Sub(time.Now()))
-		return getGithubRepository(path)
-	} else if response != nil && response.Response.StatusCode == 403 {
-		time.Sleep(time.Minute)
-		return getGithubRepository(path)
-	} else if err != nil {
-		return nil, true, err
+
+	var repository *github.Repository
+	response, err := c.retry(ctx, path, func() (*github.Response, error) {
+		var response *github.Response
+		var err error
+		repository, response, err = c.client.Repositories.Get(ctx, owner, repo)
+		return response, err
+	})
+
+	if err != nil {
+		return nil, false, githubError(path, err)
 	}
 
-	return repository, IsCacheResponse(response.Response), err
+	return newGithubRepository(repository), c.isCacheResponse(response.Response), nil
 }
 
-// parse split the given GitHub path and return the owner and repo name.
+// newGithubRepository converts the Github repository information, where all
+// fields are optional.
+func newGithubRepository(r *github.Repository) *Repository {
+	repository := &Repository{
+		FullName:      r.GetFullName(),
+		Fork:          r.GetFork(),
+		Archived:      r.GetArchived(),
+		CreatedAt:     r.GetCreatedAt().Time,
+		UpdatedAt:     r.GetUpdatedAt().Time,
+		PushedAt:      r.GetPushedAt().Time,
+		DefaultBranch: r.GetDefaultBranch(),
+		Stars:         r.GetStargazersCount(),
+	}
+
+	if r.Parent != nil {
+		repository.Parent = r.Parent.GetFullName()
+		repository.ParentDefaultBranch = r.Parent.GetDefaultBranch()
+	}
+
+	if r.Source != nil {
+		repository.Source = r.Source.GetFullName()
+	}
+
+	return repository
+}
+
+// parse split the given package path and return the owner and repo name. The
+// path must have the "host/owner/repo" layout.
 func parse(path string) (string, string) {
 	sub := strings.SplitN(path, "/", 4)
 	return sub[1], sub[2]
 }
 
-// getCommits will retrieve the commits from a Github repository. This function
-// also returns if the response was retrieved from a local cache.
-func getCommits(path string) ([]github.RepositoryCommit, bool, error) {
+// canonicalPath builds the import path of the package using the repository
+// full name ("owner/name") returned by the hosting service, keeping the
+// package directory inside the repository root.
+func canonicalPath(path, root, fullName string) string {
+	return host(path) + "/" + fullName + strings.TrimPrefix(path, root)
+}
+
+// getGithubCommits will retrieve the commits from a Github repository made
+// after the since date that touched the package directory. When the ref is
+// empty the commits of the default branch are retrieved. This function also
+// returns if the response was retrieved from a local cache.
+func (c *Checker) getGithubCommits(ctx context.Context, path, ref string, since time.Time) ([]Commit, bool, error) {
 	owner, repo := parse(path)
 	opt := &github.CommitsListOptions{
-		Path:  path,
-		Since: time.Now().Add(-unused),
+		SHA:   ref,
+		Path:  packageDir(path),
+		Since: since,
 		Until: time.Now(),
 	}
-	commits, response, err := GHClient.Repositories.ListCommits(owner, repo, opt)
-	if err, ok := err.(*github.RateLimitError); ok {
-		t := err.Rate.Reset
-		time.Sleep(t.Sub(time.Now()))
-		return getCommits(path)
-	} else if response != nil && response.Response.StatusCode == 403 {
-		time.Sleep(time.Minute)
-		return getCommits(path)
-	} else if err != nil {
-		return nil, true, err
+
+	var commits []*github.RepositoryCommit
+	response, err := c.retry(ctx, path, func() (*github.Response, error) {
+		var response *github.Response
+		var err error
+		commits, response, err = c.client.Repositories.ListCommits(ctx, owner, repo, opt)
+		return response, err
+	})
+
+	if err != nil {
+		return nil, false, githubError(path, err)
+	}
+
+	result := make([]Commit, 0, len(commits))
+	for _, commit := range commits {
+		result = append(result, Commit{
+			SHA:  commit.GetSHA(),
+			Date: commit.GetCommit().GetAuthor().GetDate(),
+		})
+	}
+
+	return result, c.isCacheResponse(response.Response), nil
+}
+
+// forkHead returns the head used to compare a fork with its parent, in the
+// "owner:branch" format, or false when the fork or its parent doesn't have the
+// information needed for the comparison.
+func forkHead(repository *Repository, ref string) (string, bool) {
+	if ref == "" {
+		ref = repository.DefaultBranch
+	}
+
+	owner := strings.SplitN(repository.FullName, "/", 2)[0]
+	if owner == "" || ref == "" || repository.ParentDefaultBranch == "" ||
+		!strings.Contains(repository.Parent, "/") {
+		return "", false
+	}
+
+	return owner + ":" + ref, true
+}
+
+// compareGithubFork compares a branch of the fork with the default branch of
+// its parent, using the parent repository compare endpoint. This function
+// also returns if the response was retrieved from a local cache.
+func (c *Checker) compareGithubFork(ctx context.Context, repository *Repository, ref string) (*Comparison, bool, error) {
+	head, ok := forkHead(repository, ref)
+	if !ok {
+		return nil, true, nil
+	}
+
+	path := "github.com/" + repository.Parent
+	owner, repo := parse(path)
+
+	var comparison *github.CommitsComparison
+	response, err := c.retry(ctx, path, func() (*github.Response, error) {
+		var response *github.Response
+		var err error
+		comparison, response, err = c.client.Repositories.CompareCommits(ctx, owner, repo, repository.ParentDefaultBranch, head)
+		return response, err
+	})
+
+	if err != nil {
+		return nil, false, githubError(path, err)
+	}
+
+	return &Comparison{
+		AheadBy:  comparison.GetAheadBy(),
+		BehindBy: comparison.GetBehindBy(),
+	}, c.isCacheResponse(response.Response), nil
+}
+
+// githubPullRequestsBranches limits the branches of the fork checked for pull
+// requests, as each branch needs its own request. Forks usually have few
+// branches, but the older ones copied all branches of the parent.
+const githubPullRequestsBranches = 10
+
+// getGithubPullRequests retrieves the pull requests sent from the branches of
+// the fork to its parent, in any state. Github only filters the pull requests
+// by the head branch, so the compared branch is checked first and then the
+// other branches of the fork. The branches that couldn't be checked are
+// reported with the unknown state, so the fork isn't taken as one without pull
+// requests. This function also returns if the responses were retrieved from a
+// local cache.
+func (c *Checker) getGithubPullRequests(ctx context.Context, repository *Repository, ref string) ([]PullRequest, bool, error) {
+	owner := strings.SplitN(repository.FullName, "/", 2)[0]
+	if owner == "" || !strings.Contains(repository.Parent, "/") {
+		return nil, true, nil
+	}
+
+	branches, complete, cache, err := c.getGithubBranches(ctx, repository.FullName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if ref == "" {
+		ref = repository.DefaultBranch
+	}
+
+	// the compared branch goes first, as it's the one with the changes
+	// compared with the parent
+	var heads []string
+	if ref != "" {
+		heads = append(heads, ref)
+	}
+	for _, branch := range branches {
+		if branch != ref {
+			heads = append(heads, branch)
+		}
+	}
+
+	path := "github.com/" + repository.Parent
+	parentOwner, parentRepo := parse(path)
+
+	result := []PullRequest{}
+	for i, branch := range heads {
+		if i >= githubPullRequestsBranches {
+			// the branches that weren't checked may have pull requests
+			result = append(result, PullRequest{Branch: branch, State: PullRequestUnknown})
+			continue
+		}
+
+		opt := &github.PullRequestListOptions{
+			State:       "all",
+			Head:        owner + ":" + branch,
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+
+		var pullRequests []*github.PullRequest
+		response, err := c.retry(ctx, path, func() (*github.Response, error) {
+			var response *github.Response
+			var err error
+			pullRequests, response, err = c.client.PullRequests.List(ctx, parentOwner, parentRepo, opt)
+			return response, err
+		})
+
+		if err != nil {
+			return nil, false, githubError(path, err)
+		}
+		cache = cache && c.isCacheResponse(response.Response)
+
+		for _, pullRequest := range pullRequests {
+			state := PullRequestOpen
+			switch {
+			case !pullRequest.GetMergedAt().IsZero():
+				state = PullRequestMerged
+			case pullRequest.GetState() == "closed":
+				state = PullRequestClosed
+			}
+
+			result = append(result, PullRequest{
+				Number: pullRequest.GetNumber(),
+				Branch: branch,
+				State:  state,
+			})
+		}
+	}
+
+	// the branches that weren't listed may also have pull requests
+	if !complete {
+		result = append(result, PullRequest{State: PullRequestUnknown})
+	}
+
+	return result, cache, nil
+}
+
+// getGithubBranches retrieves the names of the branches of a Github
+// repository, in the "owner/repo" format. Only the first page of branches is
+// retrieved, and it also returns false when the repository has more branches.
+// This function also returns if the response was retrieved from a local
+// cache.
+func (c *Checker) getGithubBranches(ctx context.Context, fullName string) ([]string, bool, bool, error) {
+	path := "github.com/" + fullName
+	owner, repo := parse(path)
+
+	var branches []*github.Branch
+	response, err := c.retry(ctx, path, func() (*github.Response, error) {
+		var response *github.Response
+		var err error
+		branches, response, err = c.client.Repositories.ListBranches(ctx, owner, repo, &github.ListOptions{PerPage: 100})
+		return response, err
+	})
+
+	if err != nil {
+		return nil, false, false, githubError(path, err)
+	}
+
+	names := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		names = append(names, branch.GetName())
+	}
+
+	return names, response.NextPage == 0, c.isCacheResponse(response.Response), nil
+}
+
+// getGithubTags retrieves the names of the tags of a Github repository, in
+// the "owner/repo" format. Only the first page of tags is retrieved, that has
+// the most recent tags. This function also returns if the response was
+// retrieved from a local cache.
+func (c *Checker) getGithubTags(ctx context.Context, fullName string) ([]string, bool, error) {
+	path := "github.com/" + fullName
+	owner, repo := parse(path)
+
+	var tags []*github.RepositoryTag
+	response, err := c.retry(ctx, path, func() (*github.Response, error) {
+		var response *github.Response
+		var err error
+		tags, response, err = c.client.Repositories.ListTags(ctx, owner, repo, &github.ListOptions{PerPage: 100})
+		return response, err
+	})
+
+	if err != nil {
+		return nil, false, githubError(path, err)
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.GetName())
+	}
+
+	return names, c.isCacheResponse(response.Response), nil
+}
+
+// githubError wraps a low level error from the Github client with the error
+// code that identifies the problem, keeping the original error as detail.
+// Errors from the context (cancellation or deadline) are returned as they are.
+func githubError(path string, err error) error {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+
+	switch e := err.(type) {
+	case Error:
+		return e
+
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return NewError(path, ErrorCodeGithubForbidden, err)
+
+	case *github.ErrorResponse:
+		switch e.Response.StatusCode {
+		case http.StatusForbidden:
+			return NewError(path, ErrorCodeGithubForbidden, err)
+		case http.StatusNotFound:
+			return NewError(path, ErrorCodeGithubNotFound, err)
+		case http.StatusUnavailableForLegalReasons:
+			return NewError(path, ErrorCodeGithubUnavailable, err)
+		}
+
+		return NewError(path, ErrorCodeGithubStatusCode, err)
+
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return NewError(path, ErrorCodeGithubParse, err)
+
+	case *url.Error:
+		// the URL is already identified by the path, so we only keep the
+		// transport problem
+		return NewError(path, ErrorCodeGithubFetch, e.Err)
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return NewError(path, ErrorCodeGithubParse, err)
 	}
 
-	return commits, IsCacheResponse(response.Response), err
+	return NewError(path, ErrorCodeGithubFetch, err)
 }
@@ -0,0 +1,156 @@
+package gddoexp
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultGitlabURL is the public GitLab API endpoint.
+const defaultGitlabURL = "https://gitlab.com/api/v4/"
+
+// gitlabProvider retrieves the project information from a GitLab instance.
+type gitlabProvider struct {
+	checker *Checker
+	baseURL *url.URL
+}
+
+// Match checks if the path has the "host/owner/repo" layout, where the owner
+// can also be a group with subgroups (e.g. "host/group/subgroup/repo").
+func (gitlabProvider) Match(path string) bool {
+	return matchRepository(path)
+}
+
+// Roots returns the possible project paths of the package path, as a project
+// can be inside any number of subgroups. The shortest path comes first, as
+// most projects aren't inside subgroups.
+func (gitlabProvider) Roots(path string) []string {
+	sub := strings.Split(path, "/")
+
+	var roots []string
+	for i := 3; i <= len(sub); i++ {
+		roots = append(roots, strings.Join(sub[:i], "/"))
+	}
+
+	return roots
+}
+
+// gitlabErrorCodes identifies the errors from GitLab API.
+var gitlabErrorCodes = hostErrorCodes{
+	fetch:      ErrorCodeGitlabFetch,
+	notFound:   ErrorCodeGitlabNotFound,
+	statusCode: ErrorCodeGitlabStatusCode,
+	parse:      ErrorCodeGitlabParse,
+}
+
+// gitlabProject is the project information returned by GitLab API. A fork has
+// the project that was forked.
+type gitlabProject struct {
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Archived          bool      `json:"archived"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	StarCount         int       `json:"star_count"`
+	ForkedFromProject *struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"forked_from_project"`
+}
+
+// gitlabCommit is a commit returned by GitLab API.
+type gitlabCommit struct {
+	ID           string    `json:"id"`
+	AuthoredDate time.Time `json:"authored_date"`
+}
+
+// projectEndpoint builds the URL of a project resource in GitLab API, where
+// the project is identified by its URL encoded path (e.g.
+// "projects/group%2Fsubgroup%2Frepo"). The root is the project path with the
+// host.
+func (p gitlabProvider) projectEndpoint(root, resource string) *url.URL {
+	project := strings.TrimPrefix(root, host(root)+"/")
+	endpoint := &url.URL{
+		Path:    "projects/" + project + resource,
+		RawPath: "projects/" + url.PathEscape(project) + resource,
+	}
+
+	return p.baseURL.ResolveReference(endpoint)
+}
+
+// Repository retrieves the project information from GitLab. The path is the
+// project path, including the subgroups. This function also returns if the
+// response was retrieved from a local cache.
+func (p gitlabProvider) Repository(ctx context.Context, path string) (*Repository, bool, error) {
+	endpoint := p.projectEndpoint(path, "")
+
+	var project gitlabProject
+	_, cache, err := p.checker.getJSON(ctx, path, endpoint.String(), gitlabErrorCodes, &project)
+	if err != nil {
+		return nil, false, err
+	}
+
+	repository := &Repository{
+		FullName:  project.PathWithNamespace,
+		Fork:      project.ForkedFromProject != nil,
+		Archived:  project.Archived,
+		CreatedAt: project.CreatedAt,
+		UpdatedAt: project.LastActivityAt,
+		Stars:     project.StarCount,
+	}
+
+	if project.ForkedFromProject != nil {
+		repository.Parent = project.ForkedFromProject.PathWithNamespace
+	}
+
+	return repository, cache, nil
+}
+
+// CommitsSince will retrieve the commits from a GitLab project made after the
+// since date that touched the package directory. The project must not be
+// inside subgroups, otherwise CommitsSinceIn should be used.
+func (p gitlabProvider) CommitsSince(ctx context.Context, path string, since time.Time) ([]Commit, bool, error) {
+	return p.CommitsSinceIn(ctx, repositoryRoot(path), path, since)
+}
+
+// CommitsSinceIn will retrieve the commits from the GitLab project of the root
+// path made after the since date that touched the package directory,
+// following the pages informed in the X-Next-Page HTTP header up to
+// maxCommitPages pages. This function also returns if all responses were
+// retrieved from a local cache.
+func (p gitlabProvider) CommitsSinceIn(ctx context.Context, root, path string, since time.Time) ([]Commit, bool, error) {
+	endpoint := p.projectEndpoint(root, "/repository/commits")
+	dir := strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+
+	var commits []Commit
+	cache := true
+	page := "1"
+
+	for pages := 0; page != "" && pages < maxCommitPages; pages++ {
+		query := url.Values{}
+		query.Set("since", since.UTC().Format(time.RFC3339))
+		if dir != "" {
+			query.Set("path", dir)
+		}
+		query.Set("per_page", "100")
+		query.Set("page", page)
+		endpoint.RawQuery = query.Encode()
+
+		var pageCommits []gitlabCommit
+		header, pageCache, err := p.checker.getJSON(ctx, path, endpoint.String(), gitlabErrorCodes, &pageCommits)
+		if err != nil {
+			return nil, false, err
+		}
+		cache = cache && pageCache
+
+		for _, commit := range pageCommits {
+			commits = append(commits, Commit{
+				SHA:  commit.ID,
+				Date: commit.AuthoredDate,
+			})
+		}
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return commits, cache, nil
+}
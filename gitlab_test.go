@@ -0,0 +1,195 @@
+package gddoexp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestGitlab(t *testing.T) {
+	forkDate := time.Now().Add(-30 * 24 * time.Hour)
+
+	var activePages, packageDirFilter int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/rafaeljusto%2Farchived":
+			fmt.Fprintf(w, `{"path_with_namespace": "rafaeljusto/archived", "archived": true, "created_at": "%s", "last_activity_at": "%s"}`,
+				time.Now().Add(-5*365*24*time.Hour).Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/api/v4/projects/rafaeljusto%2Ffork":
+			fmt.Fprintf(w, `{"path_with_namespace": "rafaeljusto/fork", "created_at": "%s", "last_activity_at": "%s", "forked_from_project": {"path_with_namespace": "golang/fork"}}`,
+				forkDate.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/api/v4/projects/golang%2Ffork":
+			fmt.Fprint(w, `{"path_with_namespace": "golang/fork"}`)
+
+		case "/api/v4/projects/rafaeljusto%2Ffork/repository/commits":
+			if r.URL.Query().Get("since") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			// the commits are split in two pages
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `[{"id": "c1", "authored_date": "%s"}]`, forkDate.Add(time.Hour).Format(time.RFC3339))
+				return
+			}
+
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprintf(w, `[{"id": "c2", "authored_date": "%s"}]`, forkDate.Add(2*time.Hour).Format(time.RFC3339))
+
+		case "/api/v4/projects/rafaeljusto%2Factive":
+			fmt.Fprintf(w, `{"path_with_namespace": "rafaeljusto/active", "created_at": "%s", "last_activity_at": "%s", "forked_from_project": {"path_with_namespace": "golang/fork"}}`,
+				time.Now().Add(-5*365*24*time.Hour).Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/api/v4/projects/rafaeljusto%2Factive/repository/commits":
+			// an active project has more pages than the analysis needs
+			page := atomic.AddInt32(&activePages, 1)
+			w.Header().Set("X-Next-Page", fmt.Sprintf("%d", page+1))
+			fmt.Fprintf(w, `[{"id": "c%d", "authored_date": "%s"}]`, page, time.Now().Format(time.RFC3339))
+
+		case "/api/v4/projects/group%2Fsubgroup%2Ffork":
+			fmt.Fprintf(w, `{"path_with_namespace": "group/subgroup/fork", "created_at": "%s", "last_activity_at": "%s", "forked_from_project": {"path_with_namespace": "group/subgroup/upstream"}}`,
+				forkDate.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/api/v4/projects/group%2Fsubgroup%2Fupstream":
+			fmt.Fprint(w, `{"path_with_namespace": "group/subgroup/upstream"}`)
+
+		case "/api/v4/projects/group%2Fsubgroup%2Ffork/repository/commits":
+			// the package directory is relative to the project inside the
+			// subgroup
+			dir := r.URL.Query().Get("path")
+			if dir != "" && dir != "pkg" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if dir == "pkg" {
+				atomic.StoreInt32(&packageDirFilter, 1)
+			}
+
+			fmt.Fprintf(w, `[{"id": "c1", "authored_date": "%s"}]`, forkDate.Add(time.Hour).Format(time.RFC3339))
+
+		case "/api/v4/projects/group%2Fsubgroup",
+			"/api/v4/projects/group%2Fsubgroup%2Fdeleted",
+			"/api/v4/projects/group%2Fsubgroup%2Fdeleted%2Fpkg":
+			w.WriteHeader(http.StatusNotFound)
+
+		case "/api/v4/projects/rafaeljusto%2Fdeleted":
+			w.WriteHeader(http.StatusNotFound)
+
+		case "/api/v4/projects/rafaeljusto%2Fbroken":
+			fmt.Fprint(w, `{`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{GitlabURL: server.URL + "/api/v4"})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	data := []struct {
+		description   string
+		path          string
+		expected      bool
+		expectedRule  string
+		expectedError error
+	}{
+		{
+			description:  "it should suppress an archived project",
+			path:         "127.0.0.1/rafaeljusto/archived",
+			expected:     true,
+			expectedRule: "archived",
+		},
+		{
+			description:  "it should suppress a fast fork",
+			path:         "127.0.0.1/rafaeljusto/fork",
+			expected:     true,
+			expectedRule: "fast-fork",
+		},
+		{
+			description: "it should keep an active fork",
+			path:        "127.0.0.1/rafaeljusto/active",
+		},
+		{
+			description:  "it should find a project inside a subgroup",
+			path:         "127.0.0.1/group/subgroup/fork/pkg",
+			expected:     true,
+			expectedRule: "fast-fork",
+		},
+		{
+			description:  "it should suppress a deleted project inside a subgroup",
+			path:         "127.0.0.1/group/subgroup/deleted/pkg",
+			expected:     true,
+			expectedRule: "gone",
+		},
+		{
+			description:  "it should suppress a deleted project",
+			path:         "127.0.0.1/rafaeljusto/deleted",
+			expected:     true,
+			expectedRule: "gone",
+		},
+		{
+			description:   "it should fail to decode the JSON response",
+			path:          "127.0.0.1/rafaeljusto/broken",
+			expectedError: gddoexp.ErrorCodeGitlabParse,
+		},
+		{
+			description:   "it should fail when the GitLab instance isn't configured",
+			path:          "gitlab.example.com/rafaeljusto/fork",
+			expectedError: gddoexp.ErrorCodeUnsupportedHost,
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+
+		if item.expectedError == nil {
+			if err != nil {
+				t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			}
+		} else if !errors.Is(err, item.expectedError) {
+			t.Errorf("[%d] %s: expected error “%v” and got “%v”", i, item.description, item.expectedError, err)
+		}
+
+		if verdict.Suppress != item.expected {
+			t.Errorf("[%d] %s: expected suppress to be %t", i, item.description, item.expected)
+		}
+
+		if verdict.Rule != item.expectedRule {
+			t.Errorf("[%d] %s: expected rule “%s” and got “%s”", i, item.description, item.expectedRule, verdict.Rule)
+		}
+	}
+
+	// with the package granularity only the package directory is analyzed
+	checker.Policy.Granularity = gddoexp.GranularityPackage
+	verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: "127.0.0.1/group/subgroup/fork/pkg"}, db)
+	if err != nil {
+		t.Errorf("unexpected error “%v” for a package inside a subgroup", err)
+	} else if verdict.Rule != "fast-fork" {
+		t.Errorf("expected rule “fast-fork” for a package inside a subgroup and got “%s”", verdict.Rule)
+	} else if atomic.LoadInt32(&packageDirFilter) == 0 {
+		t.Error("expected the commits to be filtered by the package directory inside the subgroup project")
+	}
+
+	if pages := atomic.LoadInt32(&activePages); pages > 3 {
+		t.Errorf("expected at most 3 pages of commits and got %d", pages)
+	}
+}
@@ -0,0 +1,117 @@
+package gddoexp
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gopkginPattern matches the gopkg.in import paths, like gopkg.in/pkg.v3 or
+// gopkg.in/user/pkg.v3, following the same rules of the gopkg.in service. The
+// submatches are the user, the package name, the version and the package
+// directory inside the repository.
+var gopkginPattern = regexp.MustCompile(`^gopkg\.in/(?:([a-zA-Z0-9][-a-zA-Z0-9]+)/)?([a-zA-Z][-.a-zA-Z0-9]*)\.((?:v0|v[1-9][0-9]*)(?:\.0|\.[1-9][0-9]*){0,2}(?:-unstable)?)(?:\.git)?((?:/[a-zA-Z0-9][-.a-zA-Z0-9]*)*)$`)
+
+// gopkginPath maps a gopkg.in import path to the Github repository that backs
+// it, without network requests: gopkg.in/pkg.v3 is github.com/go-pkg/pkg and
+// gopkg.in/user/pkg.v3 is github.com/user/pkg. It also returns the version,
+// that is the selector of the branch or tag served by gopkg.in. If the path
+// isn't from gopkg.in false is returned.
+func gopkginPath(path string) (repoPath, version string, ok bool) {
+	m := gopkginPattern.FindStringSubmatch(path)
+	if m == nil {
+		return "", "", false
+	}
+
+	user, name, version, dir := m[1], m[2], m[3], m[4]
+	if user == "" {
+		user = "go-" + name
+	}
+
+	return "github.com/" + user + "/" + name + dir, version, true
+}
+
+// gopkginRef resolves the version selector of a gopkg.in import path to the
+// branch or tag of the Github repository that gopkg.in serves: the one with
+// the highest version matching the selector (v3 matches v3, v3.N and v3.N.M),
+// preferring a branch over a tag with the same version. It returns an empty
+// ref, that is the default branch, when nothing matches. This function also
+// returns if the responses were retrieved from a local cache.
+func (c *Checker) gopkginRef(ctx context.Context, root, selector string) (string, bool, error) {
+	fullName := strings.TrimPrefix(root, "github.com/")
+
+	branches, _, branchesCache, err := c.getGithubBranches(ctx, fullName)
+	if err != nil {
+		return "", false, err
+	}
+
+	tags, tagsCache, err := c.getGithubTags(ctx, fullName)
+	if err != nil {
+		return "", false, err
+	}
+
+	var ref string
+	var best []int
+
+	// the branches are checked first, so a tag only replaces a branch with a
+	// higher version
+	for _, name := range append(branches, tags...) {
+		version, ok := gopkginVersion(selector, name)
+		if ok && (ref == "" || compareVersions(version, best) > 0) {
+			ref, best = name, version
+		}
+	}
+
+	return ref, branchesCache && tagsCache, nil
+}
+
+// gopkginVersion returns the numbers of the version in the branch or tag name
+// when it matches the gopkg.in version selector. The unstable selectors (e.g.
+// v3-unstable) only match the branch or tag with the same name.
+func gopkginVersion(selector, name string) ([]int, bool) {
+	if strings.HasSuffix(selector, "-unstable") {
+		return nil, name == selector
+	}
+
+	if name != selector && !strings.HasPrefix(name, selector+".") {
+		return nil, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(name, "v"), ".")
+	if len(parts) > 3 {
+		return nil, false
+	}
+
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		version[i] = n
+	}
+
+	return version, true
+}
+
+// compareVersions compares the numbers of two versions, returning a positive
+// number when a is higher than b, a negative number when it's lower and zero
+// when they're equal. Missing numbers are zero, so v3 and v3.0.0 are equal.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			return x - y
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,143 @@
+package gddoexp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestGopkgin(t *testing.T) {
+	forkDate := time.Now().Add(-30 * 24 * time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/go-yaml/yaml", "/repos/rafaeljusto/pkg", "/repos/rafaeljusto/other":
+			fmt.Fprintf(w, `{"full_name": "%s", "fork": true, "created_at": "%s", "updated_at": "%s"}`,
+				r.URL.Path[len("/repos/"):], forkDate.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+
+		case "/repos/go-yaml/yaml/branches":
+			fmt.Fprint(w, `[{"name": "master"}, {"name": "v2"}]`)
+
+		case "/repos/go-yaml/yaml/tags":
+			fmt.Fprint(w, `[{"name": "v1.0.0"}]`)
+
+		case "/repos/go-yaml/yaml/commits":
+			// only the version branch has commits made right after the fork
+			if r.URL.Query().Get("sha") != "v2" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprintf(w, `[{"sha": "c1", "commit": {"author": {"date": "%s"}}}]`, forkDate.Add(time.Hour).Format(time.RFC3339))
+
+		case "/repos/rafaeljusto/pkg/branches":
+			fmt.Fprint(w, `[{"name": "master"}, {"name": "v3-dev"}]`)
+
+		case "/repos/rafaeljusto/pkg/tags":
+			// the version is a tag series, so there's no branch with its name
+			fmt.Fprint(w, `[{"name": "v2.5.0"}, {"name": "v3.0.2"}, {"name": "v3.1.0"}, {"name": "v30.0.0"}]`)
+
+		case "/repos/rafaeljusto/pkg/commits":
+			// only the highest tag of the version has commits made right after the
+			// fork
+			if r.URL.Query().Get("sha") != "v3.1.0" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprintf(w, `[{"sha": "c1", "commit": {"author": {"date": "%s"}}}]`, forkDate.Add(time.Hour).Format(time.RFC3339))
+
+		case "/repos/rafaeljusto/other/branches":
+			fmt.Fprint(w, `[{"name": "master"}]`)
+
+		case "/repos/rafaeljusto/other/tags":
+			fmt.Fprint(w, `[{"name": "v1.0.0"}]`)
+
+		case "/repos/rafaeljusto/other/commits":
+			// no branch or tag matches the version, so the default branch is used
+			if r.URL.Query().Get("sha") != "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, `[]`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.MovedRule{}, gddoexp.FastForkRule{})
+
+	data := []struct {
+		description   string
+		path          string
+		expected      bool
+		expectedRule  string
+		expectedURL   string
+		expectedError error
+	}{
+		{
+			description:  "it should check the version branch of a gopkg.in package",
+			path:         "gopkg.in/yaml.v2/encoding",
+			expected:     true,
+			expectedRule: "fast-fork",
+			expectedURL:  "https://github.com/go-yaml/yaml",
+		},
+		{
+			description:  "it should check the highest tag of the version when the version branch doesn't exist",
+			path:         "gopkg.in/rafaeljusto/pkg.v3",
+			expected:     true,
+			expectedRule: "fast-fork",
+			expectedURL:  "https://github.com/rafaeljusto/pkg",
+		},
+		{
+			description:  "it should check the default branch when no branch or tag matches the version",
+			path:         "gopkg.in/rafaeljusto/other.v2",
+			expected:     true,
+			expectedRule: "fast-fork",
+			expectedURL:  "https://github.com/rafaeljusto/other",
+		},
+		{
+			description:   "it should fail for a gopkg.in path without version",
+			path:          "gopkg.in/yaml",
+			expectedError: gddoexp.ErrorCodeUnsupportedHost,
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, nil)
+
+		if item.expectedError == nil {
+			if err != nil {
+				t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			}
+		} else if !errors.Is(err, item.expectedError) {
+			t.Errorf("[%d] %s: expected error “%v” and got “%v”", i, item.description, item.expectedError, err)
+		}
+
+		if verdict.Suppress != item.expected {
+			t.Errorf("[%d] %s: expected suppress to be %t", i, item.description, item.expected)
+		}
+
+		if verdict.Rule != item.expectedRule {
+			t.Errorf("[%d] %s: expected rule “%s” and got “%s”", i, item.description, item.expectedRule, verdict.Rule)
+		}
+
+		if verdict.RepositoryURL != item.expectedURL {
+			t.Errorf("[%d] %s: expected repository URL “%s” and got “%s”", i, item.description, item.expectedURL, verdict.RepositoryURL)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package gddoexp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// graphqlBatchSize is the number of repositories retrieved in each GraphQL
+// query, keeping the query cost below the Github limits.
+const graphqlBatchSize = 50
+
+// graphqlHistorySize is the number of commits retrieved for each repository.
+// When there are more commits in the period, the commits are retrieved later
+// with the REST API.
+const graphqlHistorySize = 100
+
+// graphqlRepositoryFields are the repository fields used by the rules. The
+// GraphQL API doesn't report the root of the fork network like the REST API
+// does, so the parents are retrieved up to three levels to find it.
+const graphqlRepositoryFields = `
+fragment repositoryFields on Repository {
+  nameWithOwner
+  isFork
+  isArchived
+  createdAt
+  updatedAt
+  pushedAt
+  stargazers {
+    totalCount
+  }
+  parent {
+    nameWithOwner
+    isFork
+    defaultBranchRef {
+      name
+    }
+    parent {
+      nameWithOwner
+      isFork
+      parent {
+        nameWithOwner
+        isFork
+      }
+    }
+  }
+  defaultBranchRef {
+    name
+    target {
+      ... on Commit {
+        history(first: %d, since: $since) {
+          pageInfo {
+            hasNextPage
+          }
+          nodes {
+            oid
+            authoredDate
+          }
+        }
+      }
+    }
+  }
+}`
+
+// graphqlRepository is the repository information returned by Github GraphQL
+// API.
+type graphqlRepository struct {
+	NameWithOwner string    `json:"nameWithOwner"`
+	IsFork        bool      `json:"isFork"`
+	IsArchived    bool      `json:"isArchived"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	PushedAt      time.Time `json:"pushedAt"`
+	Stargazers    struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"stargazers"`
+	Parent *struct {
+		graphqlParent
+		DefaultBranchRef *struct {
+			Name string `json:"name"`
+		} `json:"defaultBranchRef"`
+	} `json:"parent"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target struct {
+			History *struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					OID          string    `json:"oid"`
+					AuthoredDate time.Time `json:"authoredDate"`
+				} `json:"nodes"`
+			} `json:"history"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+}
+
+// graphqlParent is a parent repository in the fork network, with its own
+// parent when it is also a fork.
+type graphqlParent struct {
+	NameWithOwner string         `json:"nameWithOwner"`
+	IsFork        bool           `json:"isFork"`
+	Parent        *graphqlParent `json:"parent"`
+}
+
+// source returns the root of the fork network, that is the first parent that
+// isn't a fork. It returns an empty string when the root is beyond the parents
+// retrieved.
+func (p *graphqlParent) source() string {
+	for ; p != nil; p = p.Parent {
+		if !p.IsFork {
+			return p.NameWithOwner
+		}
+	}
+
+	return ""
+}
+
+// graphqlResponse is the response of a batch query, where each repository is
+// identified by an alias. A repository that wasn't found is null.
+type graphqlResponse struct {
+	Data map[string]*graphqlRepository `json:"data"`
+}
+
+// graphqlURL builds the GraphQL API endpoint from the REST API endpoint: the
+// public API is https://api.github.com/graphql, and Github Enterprise uses
+// https://github.example.com/api/graphql.
+func graphqlURL(baseURL *url.URL) *url.URL {
+	return baseURL.ResolveReference(&url.URL{Path: "../graphql"})
+}
+
+// prefetchGithub retrieves the Github repositories of the first packages of
+// the list with a single GraphQL query, storing the responses in the memo for
+// the agents. It returns the packages covered by the query, that are at most
+// the packages of graphqlBatchSize repositories. When the query fails the
+// failure is reported to the GraphQL error callback, and the packages are
+// retrieved one by one with the REST API. It only returns an error when the
+// context is done.
+func (c *Checker) prefetchGithub(ctx context.Context, packages []database.Package, memo *fetchMemo) ([]database.Package, error) {
+	var roots []string
+	seen := make(map[string]bool)
+
+	n := 0
+	for ; n < len(packages); n++ {
+		// vanity import paths are left to the REST API, so the go-import meta
+		// tags are only retrieved when a rule needs the repository
+		resolved, ok, err := c.resolve(packages[n].Path)
+		if !ok || err != nil {
+			continue
+		}
+
+		if _, ok := c.Providers.Lookup(resolved.path).(githubProvider); !ok {
+			continue
+		}
+
+		root := repositoryRoot(resolved.path)
+		if seen[root] {
+			continue
+		}
+
+		if len(roots) == graphqlBatchSize {
+			break
+		}
+
+		seen[root] = true
+		roots = append(roots, root)
+	}
+
+	if len(roots) > 0 {
+		err := c.queryGithubRepositories(ctx, roots, memo)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// the packages fall back to the REST API, so the failure doesn't stop
+		// the analysis
+		if err != nil && c.graphqlError != nil {
+			c.graphqlError(err)
+		}
+	}
+
+	return packages[:n], nil
+}
+
+// queryGithubRepositories retrieves the information and the recent commits of
+// the repositories, in the "github.com/owner/repo" format, with a single
+// GraphQL query. The responses are stored in the memo with the same keys used
+// by the subject.
+func (c *Checker) queryGithubRepositories(ctx context.Context, roots []string, memo *fetchMemo) error {
+	since := time.Now().Add(-c.Policy.UnusedPeriod)
+
+	var query bytes.Buffer
+	var params []string
+	variables := map[string]interface{}{
+		"since": since.UTC().Format(time.RFC3339),
+	}
+
+	for i, root := range roots {
+		owner, repo := parse(root)
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!", i, i))
+		fmt.Fprintf(&query, "  r%d: repository(owner: $o%d, name: $n%d) {\n    ...repositoryFields\n  }\n", i, i, i)
+		variables[fmt.Sprintf("o%d", i)] = owner
+		variables[fmt.Sprintf("n%d", i)] = repo
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": fmt.Sprintf("query($since: GitTimestamp!, %s) {\n%s}\n", strings.Join(params, ", "), query.String()) +
+			fmt.Sprintf(graphqlRepositoryFields, graphqlHistorySize),
+		"variables": variables,
+	})
+	if err != nil {
+		return NewError(roots[0], ErrorCodeGithubFetch, err)
+	}
+
+	var response graphqlResponse
+	if err := c.postGraphQL(ctx, roots[0], body, &response); err != nil {
+		return err
+	}
+
+	for i, root := range roots {
+		alias := fmt.Sprintf("r%d", i)
+
+		// GraphQL doesn't follow the redirects of renamed or transferred
+		// repositories, so a repository that wasn't found is left to the REST
+		// API, that tells a moved repository from a deleted one
+		r := response.Data[alias]
+		if r == nil {
+			continue
+		}
+
+		repository := &Repository{
+			FullName:  r.NameWithOwner,
+			Fork:      r.IsFork,
+			Archived:  r.IsArchived,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+			PushedAt:  r.PushedAt,
+			Stars:     r.Stargazers.TotalCount,
+		}
+
+		if r.DefaultBranchRef != nil {
+			repository.DefaultBranch = r.DefaultBranchRef.Name
+		}
+
+		if r.Parent != nil {
+			repository.Parent = r.Parent.NameWithOwner
+			repository.Source = r.Parent.source()
+			if r.Parent.DefaultBranchRef != nil {
+				repository.ParentDefaultBranch = r.Parent.DefaultBranchRef.Name
+			}
+		}
+
+		memo.store(repositoryKey(root), repository, false, nil)
+
+		// an empty repository has no default branch
+		commits := []Commit{}
+		if r.DefaultBranchRef != nil {
+			history := r.DefaultBranchRef.Target.History
+			if history == nil || history.PageInfo.HasNextPage {
+				continue
+			}
+
+			for _, node := range history.Nodes {
+				commits = append(commits, Commit{
+					SHA:  node.OID,
+					Date: node.AuthoredDate,
+				})
+			}
+		}
+
+		memo.store(commitsKey(root, "", c.Policy.UnusedPeriod), commits, false, nil)
+	}
+
+	return nil
+}
+
+// postGraphQL sends a query to Github GraphQL API with the checker Github
+// credentials and decodes the JSON response into v. The path is used to
+// identify the errors.
+func (c *Checker) postGraphQL(ctx context.Context, path string, body []byte, v interface{}) error {
+	request, err := http.NewRequest("POST", c.graphqlURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return NewError(path, ErrorCodeGithubFetch, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.githubHTTPClient.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// the URL is already identified by the path, so we only keep the
+		// transport problem
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+
+		return NewError(path, ErrorCodeGithubFetch, err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return NewError(path, ErrorCodeGithubForbidden, nil)
+	default:
+		return NewError(path, ErrorCodeGithubStatusCode,
+			fmt.Errorf("status code %d", response.StatusCode))
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return NewError(path, ErrorCodeGithubParse, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package gddoexp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestShouldSuppressPackagesGraphQL(t *testing.T) {
+	var mutex sync.Mutex
+	var queries int
+	var restRequests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			mutex.Lock()
+			restRequests = append(restRequests, r.URL.Path)
+			mutex.Unlock()
+
+			// a renamed repository is only found by the REST API, that
+			// follows the redirect
+			if r.URL.Path == "/repos/rafaeljusto/old" {
+				fmt.Fprint(w, `{"full_name": "rafaeljusto/new"}`)
+				return
+			}
+
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mutex.Lock()
+		queries++
+		mutex.Unlock()
+
+		var query struct {
+			Query     string            `json:"query"`
+			Variables map[string]string `json:"variables"`
+		}
+
+		if r.Method != "POST" || json.NewDecoder(r.Body).Decode(&query) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !strings.Contains(query.Query, "fragment repositoryFields on Repository") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var data, errs []string
+		for i := 0; ; i++ {
+			owner, ok := query.Variables[fmt.Sprintf("o%d", i)]
+			if !ok {
+				break
+			}
+
+			alias := fmt.Sprintf("r%d", i)
+			fullName := owner + "/" + query.Variables[fmt.Sprintf("n%d", i)]
+
+			switch fullName {
+			case "rafaeljusto/gddoexp":
+				data = append(data, fmt.Sprintf(`"%s": {
+  "nameWithOwner": "%s",
+  "updatedAt": "%s",
+  "pushedAt": "%s",
+  "defaultBranchRef": {"target": {"history": {"pageInfo": {"hasNextPage": false}, "nodes": []}}}
+}`, alias, fullName, time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339), time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339)))
+
+			case "rafaeljusto/dns":
+				data = append(data, fmt.Sprintf(`"%s": {
+  "nameWithOwner": "%s",
+  "updatedAt": "%s",
+  "pushedAt": "%s",
+  "defaultBranchRef": {"target": {"history": {"pageInfo": {"hasNextPage": false}, "nodes": [{"oid": "c1", "authoredDate": "%s"}]}}}
+}`, alias, fullName, time.Now().Format(time.RFC3339), time.Now().Format(time.RFC3339), time.Now().Format(time.RFC3339)))
+
+			case "rafaeljusto/archived":
+				data = append(data, fmt.Sprintf(`"%s": {
+  "nameWithOwner": "%s",
+  "isArchived": true,
+  "updatedAt": "%s",
+  "pushedAt": "%s",
+  "defaultBranchRef": {"target": {"history": {"pageInfo": {"hasNextPage": false}, "nodes": []}}}
+}`, alias, fullName, time.Now().Format(time.RFC3339), time.Now().Format(time.RFC3339)))
+
+			default:
+				data = append(data, fmt.Sprintf(`"%s": null`, alias))
+				errs = append(errs, fmt.Sprintf(`{"type": "NOT_FOUND", "path": ["%s"], "message": "Could not resolve to a Repository with the name '%s'."}`, alias, fullName))
+			}
+		}
+
+		fmt.Fprintf(w, `{"data": {%s}, "errors": [%s]}`, strings.Join(data, ","), strings.Join(errs, ","))
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		BaseURL:      server.URL,
+		BitbucketURL: server.URL,
+		GraphQL:      true,
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	packages := []database.Package{
+		{Path: "github.com/rafaeljusto/gddoexp"},
+		{Path: "github.com/rafaeljusto/gddoexp/cmd/gddoexp"},
+		{Path: "github.com/rafaeljusto/dns"},
+		{Path: "github.com/rafaeljusto/gone"},
+		{Path: "github.com/rafaeljusto/old"},
+		{Path: "github.com/rafaeljusto/archived"},
+		{Path: "bitbucket.org/rafaeljusto/project"},
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			if path == "github.com/rafaeljusto/archived" {
+				return 3, nil
+			}
+			return 0, nil
+		},
+	}
+
+	expected := map[string]string{
+		"github.com/rafaeljusto/gddoexp":             "unused",
+		"github.com/rafaeljusto/gddoexp/cmd/gddoexp": "unused",
+		"github.com/rafaeljusto/dns":                 "",
+		"github.com/rafaeljusto/gone":                "gone",
+		"github.com/rafaeljusto/old":                 "moved",
+		"github.com/rafaeljusto/archived":            "importers",
+		"bitbucket.org/rafaeljusto/project":          "gone",
+	}
+
+	var responses int
+	for response := range checker.ShouldSuppressPackages(context.Background(), packages, db) {
+		responses++
+
+		if response.Error != nil {
+			t.Errorf("unexpected error “%v” for package “%s”", response.Error, response.Package.Path)
+			continue
+		}
+
+		if response.Verdict.Rule != expected[response.Package.Path] {
+			t.Errorf("expected rule “%s” and got “%s” for package “%s”", expected[response.Package.Path], response.Verdict.Rule, response.Package.Path)
+		}
+
+		// the archived repository was retrieved in advance, so the importers
+		// rule flags it without running the archived rule
+		deprecated := response.Package.Path == "github.com/rafaeljusto/archived"
+		if response.Verdict.Deprecated != deprecated {
+			t.Errorf("expected deprecated %t and got %t for package “%s”", deprecated, response.Verdict.Deprecated, response.Package.Path)
+		}
+	}
+
+	if responses != len(packages) {
+		t.Errorf("expected %d responses and got %d", len(packages), responses)
+	}
+
+	if queries != 1 {
+		t.Errorf("expected 1 GraphQL query and got %d", queries)
+	}
+
+	// packages outside Github still use the REST API of their hosting
+	// service, and the repositories that GraphQL didn't find are retrieved
+	// with the REST API
+	for _, path := range restRequests {
+		if strings.HasPrefix(path, "/repos/") && path != "/repos/rafaeljusto/gone" && path != "/repos/rafaeljusto/old" {
+			t.Errorf("unexpected REST request “%s” for a repository retrieved with GraphQL", path)
+		}
+	}
+}
+
+func TestShouldSuppressPackagesGraphQLError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/repos/rafaeljusto/gddoexp":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/gddoexp", "pushed_at": "%s"}`,
+				time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var failures []error
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		BaseURL: server.URL,
+		GraphQL: true,
+		GraphQLError: func(err error) {
+			mutex.Lock()
+			failures = append(failures, err)
+			mutex.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	packages := []database.Package{
+		{Path: "github.com/rafaeljusto/gddoexp"},
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	// the package is retrieved with the REST API after the failure
+	for response := range checker.ShouldSuppressPackages(context.Background(), packages, db) {
+		if response.Error != nil {
+			t.Errorf("unexpected error “%v” for package “%s”", response.Error, response.Package.Path)
+			continue
+		}
+
+		if response.Verdict.Rule != "unused" {
+			t.Errorf("expected rule “unused” and got “%s” for package “%s”", response.Verdict.Rule, response.Package.Path)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(failures) != 1 {
+		t.Fatalf("expected 1 GraphQL failure and got %d", len(failures))
+	}
+
+	if !errors.Is(failures[0], gddoexp.ErrorCodeGithubStatusCode) {
+		t.Errorf("unexpected GraphQL failure “%v”", failures[0])
+	}
+}
@@ -0,0 +1,238 @@
+// Package cmdutil stores the command line flags and helpers shared by the
+// gddoexp commands, so they build the checker and the policy the same way.
+package cmdutil
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gregjones/httpcache/diskcache"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+// CheckerFlags stores the flags used to build the checker: the Github
+// credentials, the hosting services and the retrieval modes.
+type CheckerFlags struct {
+	clientID          *string
+	clientSecret      *string
+	token             *string
+	appID             *int64
+	appInstallationID *int64
+	appKeyFile        *string
+	tokensFile        *string
+	gitlabURL         *string
+	vanity            *bool
+	graphql           *bool
+}
+
+// NewCheckerFlags registers the checker flags in the default flag set. It must
+// be called before flag.Parse.
+func NewCheckerFlags() *CheckerFlags {
+	return &CheckerFlags{
+		clientID:          flag.String("id", "", "Github client ID"),
+		clientSecret:      flag.String("secret", "", "Github client secret"),
+		token:             flag.String("token", "", "Github personal access token"),
+		appID:             flag.Int64("app-id", 0, "Github App ID"),
+		appInstallationID: flag.Int64("app-installation", 0, "Github App installation ID"),
+		appKeyFile:        flag.String("app-key", "", "File containing the Github App private key"),
+		tokensFile:        flag.String("tokens", "", "File containing Github personal access tokens to rotate, one per line"),
+		gitlabURL:         flag.String("gitlab", "", "API endpoint of a self-hosted GitLab instance (e.g. https://gitlab.example.com/api/v4/)"),
+		vanity:            flag.Bool("vanity", false, "Resolve vanity import paths using the go-import meta tags"),
+		graphql:           flag.Bool("graphql", false, "Retrieve the Github repositories in batches with Github GraphQL API"),
+	}
+}
+
+// Auth builds the Github credentials from the command line flags. It returns
+// nil when no credential was informed.
+func (f *CheckerFlags) Auth() (*gddoexp.GithubAuth, error) {
+	var auth gddoexp.GithubAuth
+
+	switch {
+	case *f.clientID != "" || *f.clientSecret != "":
+		auth.ID = *f.clientID
+		auth.Secret = *f.clientSecret
+
+	case *f.token != "":
+		auth.Token = *f.token
+
+	case *f.appID != 0 || *f.appInstallationID != 0 || *f.appKeyFile != "":
+		privateKey, err := ioutil.ReadFile(*f.appKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Github App private key: %s", err)
+		}
+
+		auth.App = &gddoexp.GithubApp{
+			ID:             *f.appID,
+			InstallationID: *f.appInstallationID,
+			PrivateKey:     privateKey,
+		}
+
+	default:
+		return nil, nil
+	}
+
+	if err := auth.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Github credentials: %s", err)
+	}
+
+	return &auth, nil
+}
+
+// NewChecker builds the checker with a local cache stored in $HOME/.gddoexp.
+// When a tokens file is informed, the requests are distributed among the
+// tokens, otherwise the Github credentials are used (from the flags or from
+// the environment variables). Vanity import paths and the GraphQL batch mode
+// are only used when requested, and the GraphQL failures are logged.
+func (f *CheckerFlags) NewChecker(auth *gddoexp.GithubAuth, policy gddoexp.Policy) (*gddoexp.Checker, error) {
+	config := gddoexp.Config{
+		Cache:         diskcache.New(path.Join(os.Getenv("HOME"), ".gddoexp")),
+		GitlabURL:     *f.gitlabURL,
+		ResolveVanity: *f.vanity,
+		GraphQL:       *f.graphql,
+		GraphQLError: func(err error) {
+			log.Println("GraphQL query failed, using the REST API:", err)
+		},
+	}
+
+	if *f.tokensFile != "" {
+		if auth != nil {
+			return nil, fmt.Errorf("Github credentials and tokens file can't be used together")
+		}
+
+		tokens, err := ReadTokens(*f.tokensFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var auths []gddoexp.GithubAuth
+		for _, token := range tokens {
+			auths = append(auths, gddoexp.GithubAuth{Token: token})
+		}
+
+		if config.Pool, err = gddoexp.NewTokenPool(auths...); err != nil {
+			return nil, err
+		}
+
+	} else if auth != nil {
+		config.Auth = auth
+
+	} else {
+		config.Auth = gddoexp.GithubAuthFromEnv()
+	}
+
+	checker, err := gddoexp.NewChecker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	checker.Policy = policy
+	return checker, nil
+}
+
+// ReadTokens reads the Github personal access tokens from a file, one token
+// per line.
+func ReadTokens(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening tokens file “%s”: %s", file, err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if token := strings.TrimSpace(scanner.Text()); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading tokens file “%s”: %s", file, err)
+	}
+
+	return tokens, nil
+}
+
+// PolicyFlags stores the policy file and the threshold flags. The thresholds
+// of the unused and archived rules are only registered by the commands that
+// check them.
+type PolicyFlags struct {
+	file           *string
+	unusedPeriod   *time.Duration
+	commitsLimit   *int
+	commitsPeriod  *time.Duration
+	archivedWeight *float64
+	granularity    *string
+	activity       *string
+	agents         *int
+}
+
+// NewPolicyFlags registers the policy file and the fast fork threshold flags
+// in the default flag set. It must be called before flag.Parse.
+func NewPolicyFlags() *PolicyFlags {
+	defaultPolicy := gddoexp.DefaultPolicy()
+
+	return &PolicyFlags{
+		file:          flag.String("policy", "", "JSON file with the policy thresholds"),
+		unusedPeriod:  flag.Duration("unused", defaultPolicy.UnusedPeriod, "Period without updates to consider a package unused"),
+		commitsLimit:  flag.Int("commits-limit", defaultPolicy.CommitsLimit, "Maximum number of commits in a fast fork"),
+		commitsPeriod: flag.Duration("commits-period", defaultPolicy.CommitsPeriod, "Period after the fork creation to count the commits"),
+		granularity:   flag.String("granularity", string(defaultPolicy.Granularity), "Analyze the commits of the whole repository (repository) or of the package directory (package)"),
+		agents:        flag.Int("agents", defaultPolicy.Agents, "Number of packages analyzed concurrently"),
+	}
+}
+
+// RegisterSuppressFlags registers the threshold flags used only to decide if a
+// package should be suppressed: the archived weight and the activity signal.
+func (f *PolicyFlags) RegisterSuppressFlags() {
+	defaultPolicy := gddoexp.DefaultPolicy()
+
+	f.archivedWeight = flag.Float64("archived-weight", defaultPolicy.ArchivedWeight, "How much an archived repository anticipates the unused period, between 0 and 1")
+	f.activity = flag.String("activity", string(defaultPolicy.Activity), "Last activity of a package: pushed_at, last_commit, package_commit or updated_at")
+}
+
+// Policy builds the policy from the policy file, when informed, and from the
+// threshold flags that were explicitly set.
+func (f *PolicyFlags) Policy() (gddoexp.Policy, error) {
+	policy := gddoexp.DefaultPolicy()
+
+	if *f.file != "" {
+		var err error
+		if policy, err = gddoexp.LoadPolicy(*f.file); err != nil {
+			return policy, err
+		}
+	}
+
+	flag.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "unused":
+			policy.UnusedPeriod = *f.unusedPeriod
+		case "commits-limit":
+			policy.CommitsLimit = *f.commitsLimit
+		case "commits-period":
+			policy.CommitsPeriod = *f.commitsPeriod
+		case "archived-weight":
+			policy.ArchivedWeight = *f.archivedWeight
+		case "granularity":
+			policy.Granularity = gddoexp.Granularity(*f.granularity)
+		case "activity":
+			policy.Activity = gddoexp.ActivitySignal(*f.activity)
+		case "agents":
+			policy.Agents = *f.agents
+		}
+	})
+
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("invalid policy: %s", err)
+	}
+
+	return policy, nil
+}
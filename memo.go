@@ -0,0 +1,139 @@
+package gddoexp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fetchMemo shares the hosting service responses among the packages analyzed
+// in the same run, so a repository with many packages is fetched only once.
+// When several agents ask for the same repository at the same time, only the
+// first one sends the request and the others wait for its response.
+type fetchMemo struct {
+	mutex sync.Mutex
+	calls map[string]*memoCall
+}
+
+// memoCall stores a fetch that is in progress or already done.
+type memoCall struct {
+	done   chan struct{}
+	value  interface{}
+	cache  bool
+	err    error
+	shared int
+}
+
+// newFetchMemo builds an empty memo.
+func newFetchMemo() *fetchMemo {
+	return &fetchMemo{
+		calls: make(map[string]*memoCall),
+	}
+}
+
+// do returns the call with the response of the fetch identified by the key,
+// calling fetch only when there's no response for the key yet. Failed fetches
+// are only kept when the repository doesn't exist anymore, so the next
+// packages try again after a temporary problem. It also returns if the
+// response was shared, that is, it was retrieved by another package and no
+// request was sent for this one. The call keeps if the response was retrieved
+// from a local cache.
+func (m *fetchMemo) do(key string, fetch func() (interface{}, bool, error)) (*memoCall, bool) {
+	m.mutex.Lock()
+	if call, ok := m.calls[key]; ok {
+		call.shared++
+		first := call.shared == 1
+		m.mutex.Unlock()
+
+		<-call.done
+		return call, !first
+	}
+
+	call := &memoCall{
+		done:   make(chan struct{}),
+		shared: 1,
+	}
+	m.calls[key] = call
+	m.mutex.Unlock()
+
+	call.value, call.cache, call.err = fetch()
+
+	if call.err != nil && goneStatus(call.err) == 0 {
+		m.mutex.Lock()
+		delete(m.calls, key)
+		m.mutex.Unlock()
+	}
+	close(call.done)
+
+	return call, false
+}
+
+// store adds a response retrieved in advance, like the responses of a batch
+// query. The first package that uses the response is the one that reports its
+// request. A response that already exists for the key is kept.
+func (m *fetchMemo) store(key string, value interface{}, cache bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.calls[key]; ok {
+		return
+	}
+
+	call := &memoCall{
+		done:  make(chan struct{}),
+		value: value,
+		cache: cache,
+		err:   err,
+	}
+	close(call.done)
+	m.calls[key] = call
+}
+
+// peek returns the response of the fetch identified by the key when it's
+// already done, without waiting or fetching it.
+func (m *fetchMemo) peek(key string) (*memoCall, bool) {
+	m.mutex.Lock()
+	call, ok := m.calls[key]
+	m.mutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	select {
+	case <-call.done:
+		return call, true
+	default:
+		return nil, false
+	}
+}
+
+// repositoryKey identifies the repository information in the memo by the
+// repository root path.
+func repositoryKey(root string) string {
+	return "repository " + root
+}
+
+// commitsKey identifies the commits of a package in the memo. The period is
+// part of the key, as checkers with different policies can share the memo.
+func commitsKey(path, ref string, period time.Duration) string {
+	return fmt.Sprintf("commits %s@%s %s", path, ref, period)
+}
+
+// comparisonKey identifies the comparison of a fork with its parent in the
+// memo, by the fork root path and the compared branch.
+func comparisonKey(root, ref string) string {
+	return fmt.Sprintf("comparison %s@%s", root, ref)
+}
+
+// refKey identifies the branch or tag that a gopkg.in version selector refers
+// to in the memo, by the repository root path and the selector.
+func refKey(root, selector string) string {
+	return fmt.Sprintf("ref %s@%s", root, selector)
+}
+
+// pullRequestsKey identifies the pull requests sent from a fork to its parent
+// in the memo, by the fork root path and the branch checked first.
+func pullRequestsKey(root, ref string) string {
+	return fmt.Sprintf("pull requests %s@%s", root, ref)
+}
@@ -0,0 +1,283 @@
+package gddoexp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Granularity defines if the activity of a package is analyzed using the whole
+// repository or only the package directory inside the repository.
+type Granularity string
+
+// List of possible granularities of the activity analysis.
+const (
+	// GranularityRepository analyzes the activity of the whole repository, so
+	// all packages of a repository share the same decision.
+	GranularityRepository Granularity = "repository"
+
+	// GranularityPackage analyzes only the commits that touched the package
+	// directory, so a dead subpackage inside an active repository can be
+	// found.
+	GranularityPackage Granularity = "package"
+)
+
+// ActivitySignal defines which date the unused rule uses as the last activity
+// of a package.
+type ActivitySignal string
+
+// List of possible activity signals.
+const (
+	// ActivityPushedAt uses the last time that commits were pushed to any
+	// branch of the repository. Hosting services that don't report it use the
+	// last update date of the repository instead.
+	ActivityPushedAt ActivitySignal = "pushed_at"
+
+	// ActivityLastCommit uses the date of the last commit in the default
+	// branch (or in the branch or tag of the version for gopkg.in packages).
+	ActivityLastCommit ActivitySignal = "last_commit"
+
+	// ActivityPackageCommit uses the date of the last commit that touched the
+	// package directory.
+	ActivityPackageCommit ActivitySignal = "package_commit"
+
+	// ActivityUpdatedAt uses the last update date of the repository, that
+	// also changes when the repository is starred or its description is
+	// edited.
+	ActivityUpdatedAt ActivitySignal = "updated_at"
+)
+
+// Policy stores the thresholds used by the rules and the level of concurrency
+// used when processing a list of packages. Different policies can be used for
+// different purposes, like the search index or the crawl prioritisation.
+type Policy struct {
+	// UnusedPeriod is the time that an unmodified project is considered
+	// unused.
+	UnusedPeriod time.Duration
+
+	// CommitsLimit is the maximum number of commits made in the fork so we
+	// could identify it as a fast fork.
+	CommitsLimit int
+
+	// CommitsPeriod is the period after the fork creation date that we will
+	// consider the commits a fast fork.
+	CommitsPeriod time.Duration
+
+	// ArchivedWeight defines how much the archived flag of a repository
+	// anticipates the unused period, between 0 and 1. With weight 1 an
+	// archived repository is suppressed right away, with weight 0.5 only
+	// after half of the unused period without updates, and with weight 0 the
+	// archived rule is disabled.
+	ArchivedWeight float64
+
+	// Granularity defines if the unused and fast fork rules analyze the
+	// commits of the whole repository or only the commits that touched the
+	// package directory. If empty, the whole repository is analyzed.
+	Granularity Granularity
+
+	// Activity defines the date used by the unused rule as the last activity
+	// of the package. If empty, the pushed at date is used. The pushes can't
+	// be tied to a directory, so with the package granularity the last commit
+	// that touched the package directory is used instead of the pushed at
+	// date, and the other signals of the whole repository are invalid.
+	Activity ActivitySignal
+
+	// Agents contains the number of concurrent go routines that will process
+	// a list of packages.
+	Agents int
+}
+
+// DefaultPolicy returns the policy used when none is informed: 2 years
+// without updates to consider a project unused, up to 2 commits in the first
+// week to consider a fork a fast fork and archived repositories suppressed
+// right away, analyzing the pushes to the whole repository with 4 agents.
+// Before the archived rule, archived repositories also waited for the unused
+// period; an archived weight of 0 restores that behavior.
+func DefaultPolicy() Policy {
+	return Policy{
+		UnusedPeriod:   2 * 365 * 24 * time.Hour,
+		CommitsLimit:   2,
+		CommitsPeriod:  7 * 24 * time.Hour,
+		ArchivedWeight: 1,
+		Granularity:    GranularityRepository,
+		Activity:       ActivityPushedAt,
+		Agents:         4,
+	}
+}
+
+// policyJSON is the JSON representation of the policy, where the periods are
+// stored in the time.Duration string format (e.g. "17520h").
+type policyJSON struct {
+	UnusedPeriod   *string         `json:"unused_period,omitempty"`
+	CommitsLimit   *int            `json:"commits_limit,omitempty"`
+	CommitsPeriod  *string         `json:"commits_period,omitempty"`
+	ArchivedWeight *float64        `json:"archived_weight,omitempty"`
+	Granularity    *Granularity    `json:"granularity,omitempty"`
+	Activity       *ActivitySignal `json:"activity,omitempty"`
+	Agents         *int            `json:"agents,omitempty"`
+}
+
+// MarshalJSON encodes the policy using human readable periods.
+func (p Policy) MarshalJSON() ([]byte, error) {
+	unusedPeriod := p.UnusedPeriod.String()
+	commitsPeriod := p.CommitsPeriod.String()
+
+	return json.Marshal(policyJSON{
+		UnusedPeriod:   &unusedPeriod,
+		CommitsLimit:   &p.CommitsLimit,
+		CommitsPeriod:  &commitsPeriod,
+		ArchivedWeight: &p.ArchivedWeight,
+		Granularity:    &p.Granularity,
+		Activity:       &p.Activity,
+		Agents:         &p.Agents,
+	})
+}
+
+// UnmarshalJSON decodes the policy. Fields that aren't present in the JSON
+// keep their current values, so we can override only some thresholds of the
+// default policy.
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var aux policyJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.UnusedPeriod != nil {
+		unusedPeriod, err := time.ParseDuration(*aux.UnusedPeriod)
+		if err != nil {
+			return fmt.Errorf("invalid unused period: %s", err)
+		}
+		p.UnusedPeriod = unusedPeriod
+	}
+
+	if aux.CommitsLimit != nil {
+		p.CommitsLimit = *aux.CommitsLimit
+	}
+
+	if aux.CommitsPeriod != nil {
+		commitsPeriod, err := time.ParseDuration(*aux.CommitsPeriod)
+		if err != nil {
+			return fmt.Errorf("invalid commits period: %s", err)
+		}
+		p.CommitsPeriod = commitsPeriod
+	}
+
+	if aux.ArchivedWeight != nil {
+		p.ArchivedWeight = *aux.ArchivedWeight
+	}
+
+	if aux.Granularity != nil {
+		p.Granularity = *aux.Granularity
+	}
+
+	if aux.Activity != nil {
+		p.Activity = *aux.Activity
+	}
+
+	if aux.Agents != nil {
+		p.Agents = *aux.Agents
+	}
+
+	return nil
+}
+
+// Validate checks if the thresholds are consistent.
+func (p Policy) Validate() error {
+	if p.UnusedPeriod <= 0 {
+		return fmt.Errorf("unused period must be positive")
+	}
+
+	if p.CommitsLimit < 0 {
+		return fmt.Errorf("commits limit can't be negative")
+	}
+
+	if p.CommitsPeriod <= 0 {
+		return fmt.Errorf("commits period must be positive")
+	}
+
+	if p.ArchivedWeight < 0 || p.ArchivedWeight > 1 {
+		return fmt.Errorf("archived weight must be between 0 and 1")
+	}
+
+	switch p.Granularity {
+	case "", GranularityRepository, GranularityPackage:
+	default:
+		return fmt.Errorf("granularity must be “%s” or “%s”", GranularityRepository, GranularityPackage)
+	}
+
+	switch p.Activity {
+	case "", ActivityPushedAt, ActivityLastCommit, ActivityPackageCommit, ActivityUpdatedAt:
+	default:
+		return fmt.Errorf("activity must be “%s”, “%s”, “%s” or “%s”",
+			ActivityPushedAt, ActivityLastCommit, ActivityPackageCommit, ActivityUpdatedAt)
+	}
+
+	if p.Granularity == GranularityPackage && (p.Activity == ActivityLastCommit || p.Activity == ActivityUpdatedAt) {
+		return fmt.Errorf("activity “%s” measures the whole repository and can't be used with the “%s” granularity",
+			p.Activity, GranularityPackage)
+	}
+
+	if p.Agents < 1 {
+		return fmt.Errorf("at least one agent is necessary")
+	}
+
+	return nil
+}
+
+// LoadPolicy reads a policy from a JSON file. The thresholds that aren't in
+// the file keep the values from DefaultPolicy. For example:
+//
+//	{
+//	  "unused_period": "8760h",
+//	  "commits_limit": 5,
+//	  "commits_period": "336h",
+//	  "archived_weight": 0.5,
+//	  "granularity": "package",
+//	  "activity": "package_commit",
+//	  "agents": 8
+//	}
+func LoadPolicy(filename string) (Policy, error) {
+	policy := DefaultPolicy()
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return policy, fmt.Errorf("error opening policy file “%s”: %s", filename, err)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&policy); err != nil {
+		return policy, fmt.Errorf("error decoding policy file “%s”: %s", filename, err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("invalid policy file “%s”: %s", filename, err)
+	}
+
+	return policy, nil
+}
+
+// agents returns the number of agents to use, ensuring that there's at least
+// one agent to process the packages.
+func (p Policy) agents() int {
+	if p.Agents < 1 {
+		return 1
+	}
+
+	return p.Agents
+}
+
+// activity returns the activity signal to use, considering the granularity
+// and the default value. The package granularity replaces the pushed at date,
+// as Validate rejects the other signals of the whole repository.
+func (p Policy) activity() ActivitySignal {
+	if p.Activity == "" || p.Activity == ActivityPushedAt {
+		if p.Granularity == GranularityPackage {
+			return ActivityPackageCommit
+		}
+
+		return ActivityPushedAt
+	}
+
+	return p.Activity
+}
@@ -0,0 +1,135 @@
+package gddoexp_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	data := []struct {
+		description   string
+		content       string
+		expected      gddoexp.Policy
+		expectedError bool
+	}{
+		{
+			description: "it should load all thresholds",
+			content: `{
+  "unused_period": "8760h",
+  "commits_limit": 5,
+  "commits_period": "336h",
+  "archived_weight": 0.5,
+  "granularity": "package",
+  "activity": "package_commit",
+  "agents": 8
+}`,
+			expected: gddoexp.Policy{
+				UnusedPeriod:   365 * 24 * time.Hour,
+				CommitsLimit:   5,
+				CommitsPeriod:  14 * 24 * time.Hour,
+				ArchivedWeight: 0.5,
+				Granularity:    gddoexp.GranularityPackage,
+				Activity:       gddoexp.ActivityPackageCommit,
+				Agents:         8,
+			},
+		},
+		{
+			description: "it should keep the default thresholds that aren't in the file",
+			content: `{
+  "commits_limit": 5
+}`,
+			expected: gddoexp.Policy{
+				UnusedPeriod:   2 * 365 * 24 * time.Hour,
+				CommitsLimit:   5,
+				CommitsPeriod:  7 * 24 * time.Hour,
+				ArchivedWeight: 1,
+				Granularity:    gddoexp.GranularityRepository,
+				Activity:       gddoexp.ActivityPushedAt,
+				Agents:         4,
+			},
+		},
+		{
+			description: "it should fail with an invalid period",
+			content: `{
+  "unused_period": "2 years"
+}`,
+			expectedError: true,
+		},
+		{
+			description: "it should fail with an invalid archived weight",
+			content: `{
+  "archived_weight": 1.5
+}`,
+			expectedError: true,
+		},
+		{
+			description: "it should fail with an invalid granularity",
+			content: `{
+  "granularity": "file"
+}`,
+			expectedError: true,
+		},
+		{
+			description: "it should fail with an invalid activity",
+			content: `{
+  "activity": "starred_at"
+}`,
+			expectedError: true,
+		},
+		{
+			description: "it should fail with an activity of the whole repository and the package granularity",
+			content: `{
+  "granularity": "package",
+  "activity": "last_commit"
+}`,
+			expectedError: true,
+		},
+		{
+			description: "it should fail with an invalid number of agents",
+			content: `{
+  "agents": 0
+}`,
+			expectedError: true,
+		},
+		{
+			description:   "it should fail with an invalid JSON",
+			content:       `{`,
+			expectedError: true,
+		},
+	}
+
+	for i, item := range data {
+		f, err := ioutil.TempFile("", "gddoexp-policy-")
+		if err != nil {
+			t.Fatalf("error creating policy file: %s", err)
+		}
+
+		if _, err := f.WriteString(item.content); err != nil {
+			t.Fatalf("error writing policy file: %s", err)
+		}
+		f.Close()
+
+		policy, err := gddoexp.LoadPolicy(f.Name())
+		os.Remove(f.Name())
+
+		if item.expectedError {
+			if err == nil {
+				t.Errorf("[%d] %s: expected an error", i, item.description)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+		}
+
+		if !reflect.DeepEqual(item.expected, policy) {
+			t.Errorf("[%d] %s: expected policy “%#v” and got “%#v”", i, item.description, item.expected, policy)
+		}
+	}
+}
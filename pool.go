@@ -0,0 +1,212 @@
+package gddoexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+)
+
+// TokenPool distributes the Github requests among several credentials. The
+// remaining quota of each credential is tracked from the rate limit HTTP
+// headers, and each request is sent with the credential that has more budget
+// left. Only when all credentials are exhausted the requests wait for the
+// first rate limit window to reset.
+type TokenPool struct {
+	auths []GithubAuth
+
+	mutex  sync.Mutex
+	quotas []quota
+}
+
+// quota stores the rate limit information of a credential.
+type quota struct {
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// NewTokenPool builds a pool with the given credentials. Each credential must
+// be valid.
+func NewTokenPool(auths ...GithubAuth) (*TokenPool, error) {
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("no Github credential informed to the token pool")
+	}
+
+	for i, auth := range auths {
+		if err := auth.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid Github credential %d in the token pool: %s", i, err)
+		}
+	}
+
+	return &TokenPool{
+		auths:  append([]GithubAuth(nil), auths...),
+		quotas: make([]quota, len(auths)),
+	}, nil
+}
+
+// transport builds the transport that sends the requests using the pool
+// credentials.
+func (t *TokenPool) transport(transport http.RoundTripper, baseURL *url.URL) http.RoundTripper {
+	transports := make([]http.RoundTripper, len(t.auths))
+	for i, auth := range t.auths {
+		transports[i] = auth.transport(transport, baseURL)
+	}
+
+	return &poolTransport{
+		pool:       t,
+		transports: transports,
+	}
+}
+
+// acquire selects the credential with more budget left, reserving one request
+// from its quota. Credentials without rate limit information are preferred,
+// as we don't know their budget yet. If all credentials are exhausted, it
+// returns how long we need to wait for the first one to reset.
+func (t *TokenPool) acquire() (int, time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	selected := -1
+	var wait time.Duration
+
+	for i := range t.quotas {
+		q := &t.quotas[i]
+
+		// the rate limit window was reset, so we don't know the budget anymore
+		if q.known && now.After(q.reset) {
+			q.known = false
+		}
+
+		if !q.known {
+			selected = i
+			break
+		}
+
+		if q.remaining > 0 {
+			if selected == -1 || q.remaining > t.quotas[selected].remaining {
+				selected = i
+			}
+		} else if reset := q.reset.Sub(now); wait == 0 || reset < wait {
+			wait = reset
+		}
+	}
+
+	if selected == -1 {
+		return -1, wait
+	}
+
+	if t.quotas[selected].known {
+		t.quotas[selected].remaining--
+	}
+
+	return selected, 0
+}
+
+// update stores the rate limit information of a credential from the response
+// HTTP headers, returning false when there's no information to store.
+// Responses retrieved from the local cache are ignored, as their headers are
+// outdated.
+func (t *TokenPool) update(i int, response *http.Response) bool {
+	if response.Header.Get(httpcache.XFromCache) == "1" {
+		return false
+	}
+
+	remaining, err := strconv.Atoi(response.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return false
+	}
+
+	reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.quotas[i] = quota{
+		known:     true,
+		remaining: remaining,
+		reset:     time.Unix(reset, 0),
+	}
+	return true
+}
+
+// summary returns the budget of the whole pool and the first reset date. A
+// credential without rate limit information counts as one request.
+func (t *TokenPool) summary() (remaining int, reset time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, q := range t.quotas {
+		if !q.known {
+			remaining++
+			continue
+		}
+
+		remaining += q.remaining
+		if reset.IsZero() || q.reset.Before(reset) {
+			reset = q.reset
+		}
+	}
+
+	return remaining, reset
+}
+
+// poolTransport sends each request with the pool credential that has more
+// budget left.
+type poolTransport struct {
+	pool       *TokenPool
+	transports []http.RoundTripper
+}
+
+// RoundTrip sends the request, waiting only when all credentials are
+// exhausted. When a credential reaches the rate limit the request is sent
+// again with another credential. The rate limit HTTP headers of the response
+// are replaced by the pool budget, so the Github client doesn't block the
+// requests because of a single exhausted credential.
+func (p *poolTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	for {
+		i, wait := p.pool.acquire()
+		if i == -1 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return nil, r.Context().Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		response, err := p.transports[i].RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// when the credential reached the rate limit we try another one, but
+		// requests with a body can't be sent again
+		updated := p.pool.update(i, response)
+		retry := r.Body == nil || r.Body == http.NoBody
+		if updated && retry && response.StatusCode == http.StatusForbidden &&
+			response.Header.Get("X-RateLimit-Remaining") == "0" {
+
+			response.Body.Close()
+			continue
+		}
+
+		remaining, reset := p.pool.summary()
+		response.Header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !reset.IsZero() {
+			response.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		}
+
+		return response, nil
+	}
+}
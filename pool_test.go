@@ -0,0 +1,85 @@
+package gddoexp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestTokenPool(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.Header.Get("Authorization")]++
+		mutex.Unlock()
+
+		w.Header().Set("X-RateLimit-Reset", reset)
+
+		switch r.Header.Get("Authorization") {
+		case "token exhausted":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+
+		case "token available":
+			w.Header().Set("X-RateLimit-Remaining", "4000")
+			fmt.Fprint(w, `{"fork": false}`)
+
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	pool, err := gddoexp.NewTokenPool(
+		gddoexp.GithubAuth{Token: "exhausted"},
+		gddoexp.GithubAuth{Token: "available"},
+	)
+	if err != nil {
+		t.Fatalf("error building token pool: %s", err)
+	}
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		BaseURL: server.URL,
+		Pool:    pool,
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, _, err := checker.IsFastForkPackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/dns"}); err != nil {
+			t.Errorf("[%d] unexpected error “%v”", i, err)
+		}
+	}
+
+	if requests["token exhausted"] != 1 {
+		t.Errorf("expected only one request with the exhausted token and got %d", requests["token exhausted"])
+	}
+
+	if requests["token available"] != 5 {
+		t.Errorf("expected 5 requests with the available token and got %d", requests["token available"])
+	}
+}
+
+func TestNewTokenPool(t *testing.T) {
+	if _, err := gddoexp.NewTokenPool(); err == nil {
+		t.Error("expected an error for an empty pool")
+	}
+
+	if _, err := gddoexp.NewTokenPool(gddoexp.GithubAuth{Token: "abc123"}, gddoexp.GithubAuth{}); err == nil {
+		t.Error("expected an error for an invalid credential")
+	}
+}
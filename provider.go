@@ -0,0 +1,287 @@
+package gddoexp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider retrieves the repository information from a hosting service, so
+// the rules can analyze packages from any hosting service. New hosting
+// services (e.g. Gitea or an internal forge) are supported by registering a
+// Provider in the checker Providers registry.
+type Provider interface {
+	// Match returns true when the provider can analyze the package path. The
+	// registry already selected the provider by the host, so this is used to
+	// check the path layout.
+	Match(path string) bool
+
+	// Repository retrieves the repository information of the package. It also
+	// returns if the response was retrieved from a local cache.
+	Repository(ctx context.Context, path string) (*Repository, bool, error)
+
+	// CommitsSince retrieves the commits of the package repository made after
+	// the since date that touched the package directory, so for the path of
+	// the repository root all commits are retrieved. It also returns if the
+	// responses were retrieved from a local cache.
+	CommitsSince(ctx context.Context, path string, since time.Time) ([]Commit, bool, error)
+}
+
+// maxCommitPages limits the pages of commits retrieved by the providers that
+// paginate the commits of the period. The rules only need the most recent
+// commits, so the remaining pages of an active repository would be wasted
+// requests, like the single page retrieved from Github.
+const maxCommitPages = 3
+
+// RefProvider is implemented by the providers that can retrieve the commits of
+// a branch or tag other than the default branch, like the version branches of
+// gopkg.in import paths.
+type RefProvider interface {
+	// CommitsSinceRef works like CommitsSince, but only for the commits
+	// reachable from the ref.
+	CommitsSinceRef(ctx context.Context, path, ref string, since time.Time) ([]Commit, bool, error)
+}
+
+// NestedProvider is implemented by the providers whose repositories can be
+// nested in groups, like the GitLab subgroups, so the repository root isn't
+// always the "host/owner/repo" prefix of the package path. The path informed
+// to the Repository method of these providers must be the repository root.
+type NestedProvider interface {
+	// Roots returns the paths that can be the repository root of the package
+	// path, from the shortest to the longest. They are checked in order until
+	// a repository is found.
+	Roots(path string) []string
+
+	// CommitsSinceIn works like CommitsSince, for a package inside the given
+	// repository root.
+	CommitsSinceIn(ctx context.Context, root, path string, since time.Time) ([]Commit, bool, error)
+}
+
+// ForkProvider is implemented by the providers that can compare a fork with its
+// parent repository, so fast forks are detected by the commits that weren't
+// merged in the parent instead of by the commit dates.
+type ForkProvider interface {
+	// CompareWithParent compares a branch of the fork with the default branch
+	// of its parent. When the ref is empty the default branch of the fork is
+	// used. It returns nil when the repository doesn't have the information
+	// needed for the comparison. It also returns if the response was
+	// retrieved from a local cache.
+	CompareWithParent(ctx context.Context, repository *Repository, ref string) (*Comparison, bool, error)
+
+	// PullRequests retrieves the pull requests sent from any branch of the
+	// fork to its parent, in any state, checking the ref first. When the ref
+	// is empty the default branch of the fork is checked first. The branches
+	// that couldn't be checked are reported with the unknown state. It also
+	// returns if the responses were retrieved from a local cache.
+	PullRequests(ctx context.Context, repository *Repository, ref string) ([]PullRequest, bool, error)
+}
+
+// ProviderRegistry selects the provider of a package by the host of the
+// package path. It is safe to register providers while packages are being
+// checked.
+type ProviderRegistry struct {
+	mutex     sync.RWMutex
+	providers []hostProvider
+}
+
+// hostProvider stores a provider with the host pattern that it serves.
+type hostProvider struct {
+	pattern  string
+	provider Provider
+}
+
+// NewProviderRegistry builds an empty registry.
+func NewProviderRegistry() *ProviderRegistry {
+	return new(ProviderRegistry)
+}
+
+// Register adds a provider for the hosts that match the pattern. The pattern
+// uses the path.Match syntax, so "gitlab.example.com" or "*.example.com" are
+// valid patterns. A provider registered again with the same pattern replaces
+// the previous one. The patterns are checked in the order that they were
+// registered.
+func (r *ProviderRegistry) Register(pattern string, provider Provider) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.providers {
+		if r.providers[i].pattern == pattern {
+			r.providers[i].provider = provider
+			return nil
+		}
+	}
+
+	r.providers = append(r.providers, hostProvider{
+		pattern:  pattern,
+		provider: provider,
+	})
+	return nil
+}
+
+// Lookup returns the provider of the package path, or nil if there's no
+// provider for it.
+func (r *ProviderRegistry) Lookup(packagePath string) Provider {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	h := host(packagePath)
+	for _, p := range r.providers {
+		if ok, _ := path.Match(p.pattern, h); ok && p.provider.Match(packagePath) {
+			return p.provider
+		}
+	}
+
+	return nil
+}
+
+// host returns the hosting service of the package path (e.g. github.com).
+func host(packagePath string) string {
+	return strings.SplitN(packagePath, "/", 2)[0]
+}
+
+// matchRepository returns true when the package path follows the
+// "host/owner/repo" layout used by the built-in providers.
+func matchRepository(packagePath string) bool {
+	sub := strings.SplitN(packagePath, "/", 4)
+	return len(sub) >= 3 && sub[1] != "" && sub[2] != ""
+}
+
+// resolvedPath stores where the package information is retrieved from.
+type resolvedPath struct {
+	// path is the package path in its hosting service.
+	path string
+
+	// repoURL is the repository that backs an import path from another
+	// domain. It is empty when the package is retrieved by its own path.
+	repoURL string
+
+	// ref is the branch or tag that the import path refers to. It is empty
+	// for the default branch.
+	ref string
+
+	// version is true when the ref is a gopkg.in version selector (e.g. v3),
+	// that must be resolved to a branch or tag before it's used.
+	version bool
+}
+
+// resolve returns where the package information should be retrieved from,
+// without network requests. Paths from a supported hosting service are
+// returned as they are and gopkg.in paths are mapped to their Github
+// repositories and version selectors. It returns false when the path is a
+// vanity import path, that must be resolved with resolveVanity.
+func (c *Checker) resolve(path string) (resolvedPath, bool, error) {
+	if c.Providers.Lookup(path) != nil {
+		return resolvedPath{path: path}, true, nil
+	}
+
+	if repoPath, version, ok := gopkginPath(path); ok {
+		owner, repo := parse(repoPath)
+		return resolvedPath{
+			path:    repoPath,
+			repoURL: "https://github.com/" + owner + "/" + repo,
+			ref:     version,
+			version: true,
+		}, true, nil
+	}
+
+	if c.vanity == nil {
+		return resolvedPath{}, false, NewError(path, ErrorCodeUnsupportedHost, nil)
+	}
+
+	return resolvedPath{}, false, nil
+}
+
+// resolveVanity maps a vanity import path to the repository that backs it,
+// using the go-import meta tags. The subject only calls it when a rule needs
+// the repository, so the packages with importers don't send the request. This
+// function also returns if the response was retrieved from a local cache.
+func (c *Checker) resolveVanity(ctx context.Context, path string) (resolvedPath, bool, error) {
+	root, found, cache, err := c.vanity.lookup(ctx, path)
+	if err != nil {
+		return resolvedPath{}, cache, err
+	} else if !found {
+		return resolvedPath{}, cache, NewError(path, ErrorCodeUnsupportedHost, fmt.Errorf("no go-import meta tag"))
+	}
+
+	repoPath, err := repositoryPath(root.repoURL)
+	if err != nil {
+		return resolvedPath{}, cache, NewError(path, ErrorCodeVanityParse, err)
+	}
+
+	// keep the package directory inside the repository
+	repoPath += strings.TrimPrefix(path, root.prefix)
+
+	if c.Providers.Lookup(repoPath) == nil {
+		return resolvedPath{}, cache, NewError(path, ErrorCodeUnsupportedHost, fmt.Errorf("repository %s", root.repoURL))
+	}
+
+	return resolvedPath{path: repoPath, repoURL: root.repoURL}, cache, nil
+}
+
+// repositoryRoot returns the path of the repository root (host/owner/repo)
+// of a package path with the "host/owner/repo" layout.
+func repositoryRoot(packagePath string) string {
+	sub := strings.SplitN(packagePath, "/", 4)
+	if len(sub) < 4 {
+		return packagePath
+	}
+
+	return strings.Join(sub[:3], "/")
+}
+
+// packageDir returns the directory of the package relative to the repository
+// root, or an empty string for the package in the repository root. The path
+// must have the "host/owner/repo" layout.
+func packageDir(packagePath string) string {
+	sub := strings.SplitN(packagePath, "/", 4)
+	if len(sub) < 4 {
+		return ""
+	}
+
+	return sub[3]
+}
+
+// repository retrieves the repository information using the provider of the
+// package path. It also returns if the response was retrieved from a local
+// cache.
+func (c *Checker) repository(ctx context.Context, path string) (*Repository, bool, error) {
+	provider := c.Providers.Lookup(path)
+	if provider == nil {
+		return nil, true, NewError(path, ErrorCodeUnsupportedHost, nil)
+	}
+
+	return provider.Repository(ctx, path)
+}
+
+// commits retrieves the commits made after the since date using the provider
+// of the package path, that is inside the repository root. When a ref is
+// informed and the provider supports it, the commits of the branch or tag are
+// retrieved, falling back to the default branch when the ref doesn't exist. It
+// also returns if the responses were retrieved from a local cache.
+func (c *Checker) commits(ctx context.Context, root, path, ref string, since time.Time) ([]Commit, bool, error) {
+	provider := c.Providers.Lookup(path)
+	if provider == nil {
+		return nil, true, NewError(path, ErrorCodeUnsupportedHost, nil)
+	}
+
+	if nestedProvider, ok := provider.(NestedProvider); ok {
+		return nestedProvider.CommitsSinceIn(ctx, root, path, since)
+	}
+
+	if refProvider, ok := provider.(RefProvider); ok && ref != "" {
+		commits, cache, err := refProvider.CommitsSinceRef(ctx, path, ref, since)
+		if goneStatus(err) != http.StatusNotFound {
+			return commits, cache, err
+		}
+	}
+
+	return provider.CommitsSince(ctx, path, since)
+}
@@ -0,0 +1,106 @@
+package gddoexp_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	registry := gddoexp.NewProviderRegistry()
+
+	forge := providerMock{name: "forge"}
+	gitea := providerMock{name: "gitea"}
+	other := providerMock{name: "other"}
+
+	// the specific host is registered first, so it has precedence over the
+	// host pattern
+	if err := registry.Register("forge.example.com", forge); err != nil {
+		t.Fatalf("unexpected error registering provider: %s", err)
+	}
+
+	if err := registry.Register("*.example.com", gitea); err != nil {
+		t.Fatalf("unexpected error registering provider: %s", err)
+	}
+
+	if err := registry.Register("[", other); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+
+	data := []struct {
+		description string
+		path        string
+		expected    gddoexp.Provider
+	}{
+		{
+			description: "it should select the provider of the host",
+			path:        "forge.example.com/rafaeljusto/gddoexp",
+			expected:    forge,
+		},
+		{
+			description: "it should select the provider of a host pattern",
+			path:        "gitea.example.com/rafaeljusto/gddoexp",
+			expected:    gitea,
+		},
+		{
+			description: "it should ignore a path that the provider doesn't match",
+			path:        "gitea.example.com/rafaeljusto",
+		},
+		{
+			description: "it should not select a provider for an unknown host",
+			path:        "example.net/rafaeljusto/gddoexp",
+		},
+	}
+
+	for i, item := range data {
+		if provider := registry.Lookup(item.path); !reflect.DeepEqual(item.expected, provider) {
+			t.Errorf("[%d] %s: expected provider “%v” and got “%v”", i, item.description, item.expected, provider)
+		}
+	}
+}
+
+func TestCheckerProvider(t *testing.T) {
+	checker, err := gddoexp.NewChecker(gddoexp.Config{})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.UnusedRule{})
+
+	if err := checker.Providers.Register("forge.example.com", providerMock{name: "forge"}); err != nil {
+		t.Fatalf("unexpected error registering provider: %s", err)
+	}
+
+	suppress, _, err := checker.ShouldSuppressPackage(context.Background(), database.Package{Path: "forge.example.com/rafaeljusto/gddoexp"}, nil)
+	if err != nil {
+		t.Errorf("unexpected error “%v”", err)
+	}
+
+	if !suppress {
+		t.Error("expected package to be suppressed")
+	}
+}
+
+type providerMock struct {
+	name string
+}
+
+func (p providerMock) Match(path string) bool {
+	return len(strings.Split(path, "/")) == 3
+}
+
+func (p providerMock) Repository(ctx context.Context, path string) (*gddoexp.Repository, bool, error) {
+	return &gddoexp.Repository{
+		FullName:  "rafaeljusto/gddoexp",
+		CreatedAt: time.Now().Add(-5 * 365 * 24 * time.Hour),
+		UpdatedAt: time.Now().Add(-3 * 365 * 24 * time.Hour),
+	}, true, nil
+}
+
+func (p providerMock) CommitsSince(ctx context.Context, path string, since time.Time) ([]gddoexp.Commit, bool, error) {
+	return nil, true, nil
+}
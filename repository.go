@@ -0,0 +1,219 @@
+package gddoexp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Repository stores the information of a repository used by the rules,
+// independent of the hosting service.
+type Repository struct {
+	// FullName is the repository name in the "owner/name" format, as reported
+	// by the hosting service. It can be different from the package path when
+	// the repository was renamed or transferred.
+	FullName string
+
+	// Fork is true when the repository is a copy of another repository.
+	Fork bool
+
+	// Parent is the full name of the repository that was forked, when known.
+	Parent string
+
+	// Source is the full name of the root repository of the fork network,
+	// when known. For a fork of a fork it is different from the parent.
+	Source string
+
+	// DefaultBranch is the branch checked out by default, when known.
+	DefaultBranch string
+
+	// ParentDefaultBranch is the default branch of the parent repository,
+	// when known.
+	ParentDefaultBranch string
+
+	// Archived is true when the owner marked the repository as read-only.
+	Archived bool
+
+	// CreatedAt is the creation date of the repository, that for a fork is
+	// the fork date.
+	CreatedAt time.Time
+
+	// UpdatedAt is the last time that the repository was modified.
+	UpdatedAt time.Time
+
+	// PushedAt is the last time that commits were pushed to the repository,
+	// when the hosting service reports it.
+	PushedAt time.Time
+
+	// Stars is the number of users that starred the repository, when the
+	// hosting service reports it.
+	Stars int
+}
+
+// activeAt returns the last time that commits were pushed to the repository,
+// or the last update date when the hosting service doesn't report the pushes.
+func (r *Repository) activeAt() time.Time {
+	if r.PushedAt.IsZero() {
+		return r.UpdatedAt
+	}
+
+	return r.PushedAt
+}
+
+// Commit stores the information of a commit used by the rules, independent of
+// the hosting service.
+type Commit struct {
+	// SHA identifies the commit.
+	SHA string
+
+	// Date is when the commit was authored.
+	Date time.Time
+}
+
+// Comparison stores the difference between a branch of a fork and the
+// default branch of its parent repository.
+type Comparison struct {
+	// AheadBy is the number of commits of the fork that aren't in the
+	// parent.
+	AheadBy int
+
+	// BehindBy is the number of commits of the parent that aren't in the
+	// fork.
+	BehindBy int
+}
+
+// ForkNetwork stores the position of a fork in its fork network, found by
+// walking the parents of the fork.
+type ForkNetwork struct {
+	// Root is the full name of the repository that started the network, or
+	// of the last repository found when the upstream doesn't exist anymore.
+	Root string
+
+	// Depth is the number of forks from the root to the repository: 1 for a
+	// fork of the root, 2 for a fork of a fork, and so on.
+	Depth int
+
+	// UpstreamGone is true when the parent, or another repository between
+	// the fork and the root, was deleted. The fork may have become the
+	// canonical copy of the project.
+	UpstreamGone bool
+}
+
+// PullRequestState is the situation of a pull request in the parent
+// repository.
+type PullRequestState string
+
+// List of possible pull request states.
+const (
+	// PullRequestOpen is a pull request that is still being reviewed.
+	PullRequestOpen PullRequestState = "open"
+
+	// PullRequestClosed is a pull request that was closed without merging.
+	PullRequestClosed PullRequestState = "closed"
+
+	// PullRequestMerged is a pull request that was merged into the parent.
+	PullRequestMerged PullRequestState = "merged"
+
+	// PullRequestUnknown is used for the branches of the fork that couldn't be
+	// checked for pull requests, like when the fork has too many branches.
+	PullRequestUnknown PullRequestState = "unknown"
+)
+
+// PullRequest stores the information of a pull request sent from a fork to its
+// parent repository, independent of the hosting service.
+type PullRequest struct {
+	// Number identifies the pull request in the parent repository.
+	Number int
+
+	// Branch is the branch of the fork with the proposed changes.
+	Branch string
+
+	// State is the situation of the pull request.
+	State PullRequestState
+}
+
+// hostErrorCodes stores the error codes used to report the problems of a
+// hosting service API.
+type hostErrorCodes struct {
+	fetch      ErrorCode
+	notFound   ErrorCode
+	statusCode ErrorCode
+	parse      ErrorCode
+}
+
+// getJSON sends a request to a hosting service API and decodes the JSON
+// response into v. The errors are identified with the hosting service error
+// codes. This function also returns the HTTP headers of the response and if it
+// was retrieved from a local cache.
+func (c *Checker) getJSON(ctx context.Context, path, endpoint string, codes hostErrorCodes, v interface{}) (http.Header, bool, error) {
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, false, NewError(path, codes.fetch, err)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+
+		// the URL is already identified by the path, so we only keep the
+		// transport problem
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+
+		return nil, false, NewError(path, codes.fetch, err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, NewError(path, codes.notFound, nil)
+	default:
+		return nil, false, NewError(path, codes.statusCode,
+			fmt.Errorf("status code %d", response.StatusCode))
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, false, NewError(path, codes.parse, err)
+	}
+
+	return response.Header, c.isCacheResponse(response), nil
+}
+
+// pullRequestsState summarizes the state of the pull requests sent from a fork:
+// open while any of them is still open, unknown when some branches couldn't be
+// checked, merged when any of them was merged and closed otherwise. It is
+// empty when there's no pull request.
+func pullRequestsState(pullRequests []PullRequest) PullRequestState {
+	var state PullRequestState
+	for _, pullRequest := range pullRequests {
+		switch pullRequest.State {
+		case PullRequestOpen:
+			return PullRequestOpen
+		case PullRequestUnknown:
+			state = PullRequestUnknown
+		case PullRequestMerged:
+			if state != PullRequestUnknown {
+				state = PullRequestMerged
+			}
+		case PullRequestClosed:
+			if state == "" {
+				state = PullRequestClosed
+			}
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,104 @@
+package gddoexp
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// RetryPolicy defines how the Github requests are sent again when the rate
+// limit is reached. The interval between attempts grows exponentially, with
+// some randomization so concurrent agents don't retry at the same time.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times that a request is sent,
+	// including the first attempt.
+	MaxAttempts int
+
+	// InitialInterval is the time waited after the first failed attempt.
+	InitialInterval time.Duration
+
+	// MaxInterval limits the time waited between two attempts.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor used to increase the interval after each
+	// failed attempt.
+	Multiplier float64
+
+	// Jitter is the randomization factor, between 0 and 1, applied to each
+	// interval. For example, with a jitter of 0.5 an interval of 10 seconds
+	// becomes a random interval between 5 and 15 seconds.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is informed: up
+// to 5 attempts, starting with an interval of 1 second, doubling it until it
+// reaches 1 minute.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+		Jitter:          0.5,
+	}
+}
+
+// interval returns the time to wait after the given failed attempt (starting
+// at 1).
+func (r RetryPolicy) interval(attempt int) time.Duration {
+	interval := float64(r.InitialInterval)
+	for i := 1; i < attempt; i++ {
+		interval *= r.Multiplier
+		if interval >= float64(r.MaxInterval) {
+			interval = float64(r.MaxInterval)
+			break
+		}
+	}
+
+	if r.Jitter > 0 {
+		delta := r.Jitter * interval
+		interval = interval - delta + rand.Float64()*2*delta
+	}
+
+	return time.Duration(interval)
+}
+
+// retry sends a Github request until it succeeds, fails with an error that
+// isn't related to the rate limit, or the maximum number of attempts is
+// reached. In the last case an ErrorCodeGithubForbidden error is returned.
+// When Github informs when the rate limit resets, we wait until then.
+func (c *Checker) retry(ctx context.Context, path string, request func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := request()
+
+		var wait time.Duration
+		if rateLimitErr, ok := err.(*github.RateLimitError); ok {
+			wait = rateLimitErr.Rate.Reset.Sub(time.Now())
+		} else if abuseErr, ok := err.(*github.AbuseRateLimitError); ok && abuseErr.RetryAfter != nil {
+			wait = *abuseErr.RetryAfter
+		} else if response != nil && response.StatusCode == http.StatusForbidden {
+			wait = c.retryPolicy.interval(attempt)
+		} else {
+			return response, err
+		}
+
+		if attempt >= c.retryPolicy.MaxAttempts {
+			return response, NewError(path, ErrorCodeGithubForbidden, err)
+		}
+
+		if wait <= 0 {
+			wait = c.retryPolicy.interval(attempt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return response, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gddoexp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestRetry(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		BaseURL: server.URL,
+		Retry: gddoexp.RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     10 * time.Millisecond,
+			Multiplier:      2,
+			Jitter:          0.5,
+		},
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	_, _, err = checker.IsFastForkPackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/dns"})
+	if gddoexpErr, ok := err.(gddoexp.Error); !ok || gddoexpErr.Code != gddoexp.ErrorCodeGithubForbidden {
+		t.Errorf("expected a forbidden error and got “%v”", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("expected 3 attempts and got %d", requests)
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		BaseURL: server.URL,
+		Retry: gddoexp.RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: time.Hour,
+			MaxInterval:     time.Hour,
+			Multiplier:      2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err = checker.IsFastForkPackage(ctx, database.Package{Path: "github.com/rafaeljusto/dns"})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the request to be cancelled and got “%v”", err)
+	}
+}
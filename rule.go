@@ -0,0 +1,208 @@
+package gddoexp
+
+import "sync"
+
+// Rule is a single check used to decide if a package should be suppressed.
+// Rules are evaluated in order by a RuleSet, and the first rule that matches
+// decides the package destiny.
+type Rule interface {
+	// Name identifies the rule inside a RuleSet, so it can be removed or
+	// reordered later.
+	Name() string
+
+	// Check analyzes the package, reporting if the rule matched and why. The
+	// subject loads the package information on demand, so a rule only pays for
+	// the requests that it really needs.
+	Check(s *Subject) (RuleResult, error)
+}
+
+// RuleResult stores the outcome of a rule check.
+type RuleResult struct {
+	// Matched is true when the rule is conclusive about the package. When a
+	// rule matches the evaluation stops, otherwise the next rule is checked.
+	Matched bool
+
+	// Suppress is the decision taken by a matched rule.
+	Suppress bool
+
+	// Reason describes in a human readable message why the rule matched or
+	// not.
+	Reason string
+
+	// Evidence stores the values used to take the decision, like the number
+	// of days without updates.
+	Evidence Evidence
+
+	// CanonicalPath is the import path in the new location of the package,
+	// when the rule detected that it was moved.
+	CanonicalPath string
+
+	// Deprecated flags a package that is kept, but that shouldn't be used by
+	// new projects.
+	Deprecated bool
+
+	// PullRequest is the state of the pull requests sent from the fork to its
+	// parent, when the rule checked them.
+	PullRequest PullRequestState
+
+	// Supersedes is the import path of the parent package, when the rule
+	// detected that the fork became its maintained successor.
+	Supersedes string
+
+	// DuplicateOf is the import path of the canonical package, when the rule
+	// detected that the package is a copy of it.
+	DuplicateOf string
+}
+
+// RuleSet is an ordered list of rules. It is safe to change the rules while
+// packages are being checked, but the change will only affect the packages
+// that didn't start the evaluation yet.
+type RuleSet struct {
+	mutex sync.RWMutex
+	rules []Rule
+}
+
+// NewRuleSet builds a rule set with the given rules, in the same order.
+func NewRuleSet(rules ...Rule) *RuleSet {
+	return &RuleSet{
+		rules: append([]Rule(nil), rules...),
+	}
+}
+
+// NewDefaultRuleSet builds a rule set with the rules used by this library:
+// importers, gone, moved, archived, unused and fast fork. Any other project
+// that imports the package stops the evaluation before a request is sent to
+// Github.
+func NewDefaultRuleSet() *RuleSet {
+	return NewRuleSet(
+		ImportersRule{},
+		GoneRule{},
+		MovedRule{},
+		ArchivedRule{},
+		UnusedRule{},
+		FastForkRule{},
+	)
+}
+
+// DefaultRules is the rule set used by ShouldSuppressPackage and
+// ShouldSuppressPackages. Change it to add, remove or reorder the rules.
+var DefaultRules = NewDefaultRuleSet()
+
+// Add appends a rule to the end of the rule set.
+func (r *RuleSet) Add(rule Rule) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.rules = append(r.rules, rule)
+}
+
+// InsertBefore adds a rule right before the rule with the given name. If
+// there's no rule with this name false is returned and nothing is changed.
+func (r *RuleSet) InsertBefore(name string, rule Rule) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := r.index(name)
+	if i == -1 {
+		return false
+	}
+
+	r.rules = append(r.rules, nil)
+	copy(r.rules[i+1:], r.rules[i:])
+	r.rules[i] = rule
+	return true
+}
+
+// Remove drops the rule with the given name. It returns false if the rule
+// wasn't found.
+func (r *RuleSet) Remove(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := r.index(name)
+	if i == -1 {
+		return false
+	}
+
+	r.rules = append(r.rules[:i], r.rules[i+1:]...)
+	return true
+}
+
+// MoveBefore changes the position of the rule identified by name, so it is
+// checked right before the rule identified by before. It returns false if one
+// of the rules wasn't found or if both names are the same, as a rule can't be
+// moved before itself.
+func (r *RuleSet) MoveBefore(name, before string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := r.index(name)
+	if i == -1 || r.index(before) == -1 || name == before {
+		return false
+	}
+
+	rule := r.rules[i]
+	r.rules = append(r.rules[:i], r.rules[i+1:]...)
+
+	j := r.index(before)
+	r.rules = append(r.rules, nil)
+	copy(r.rules[j+1:], r.rules[j:])
+	r.rules[j] = rule
+	return true
+}
+
+// Rules returns a copy of the current rules, in the order that they are
+// checked.
+func (r *RuleSet) Rules() []Rule {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return append([]Rule(nil), r.rules...)
+}
+
+// index returns the position of the rule with the given name or -1 if it
+// wasn't found. The caller must hold the lock.
+func (r *RuleSet) index(name string) int {
+	for i, rule := range r.rules {
+		if rule.Name() == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// evaluate checks the rules in order until one of them matches, building the
+// verdict of the package. If no rule matches the package is not suppressed.
+func (r *RuleSet) evaluate(s *Subject) (Verdict, error) {
+	var verdict Verdict
+	rules := r.Rules()
+
+	for i, rule := range rules {
+		verdict.Checked = append(verdict.Checked, rule.Name())
+
+		result, err := rule.Check(s)
+		if err != nil {
+			return verdict, err
+		}
+
+		if result.Matched {
+			verdict.Suppress = result.Suppress
+			verdict.Rule = rule.Name()
+			verdict.Reason = result.Reason
+			verdict.Evidence = result.Evidence
+			verdict.CanonicalPath = result.CanonicalPath
+			verdict.Deprecated = result.Deprecated
+			verdict.PullRequest = result.PullRequest
+			verdict.Supersedes = result.Supersedes
+			verdict.DuplicateOf = result.DuplicateOf
+
+			for _, skipped := range rules[i+1:] {
+				verdict.Skipped = append(verdict.Skipped, skipped.Name())
+			}
+			break
+		}
+	}
+
+	return verdict, nil
+}
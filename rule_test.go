@@ -0,0 +1,1198 @@
+package gddoexp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestRuleSet(t *testing.T) {
+	data := []struct {
+		description string
+		change      func(*gddoexp.RuleSet) bool
+		expected    []string
+		expectedOK  bool
+	}{
+		{
+			description: "it should add a rule to the end",
+			change: func(r *gddoexp.RuleSet) bool {
+				r.Add(ruleMock{name: "custom"})
+				return true
+			},
+			expected:   []string{"importers", "gone", "moved", "archived", "unused", "fast-fork", "custom"},
+			expectedOK: true,
+		},
+		{
+			description: "it should insert a rule before another",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.InsertBefore("unused", ruleMock{name: "custom"})
+			},
+			expected:   []string{"importers", "gone", "moved", "archived", "custom", "unused", "fast-fork"},
+			expectedOK: true,
+		},
+		{
+			description: "it should fail to insert a rule before an unknown rule",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.InsertBefore("unknown", ruleMock{name: "custom"})
+			},
+			expected: []string{"importers", "gone", "moved", "archived", "unused", "fast-fork"},
+		},
+		{
+			description: "it should remove a rule",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.Remove("unused")
+			},
+			expected:   []string{"importers", "gone", "moved", "archived", "fast-fork"},
+			expectedOK: true,
+		},
+		{
+			description: "it should fail to remove an unknown rule",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.Remove("unknown")
+			},
+			expected: []string{"importers", "gone", "moved", "archived", "unused", "fast-fork"},
+		},
+		{
+			description: "it should move a rule",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.MoveBefore("fast-fork", "importers")
+			},
+			expected:   []string{"fast-fork", "importers", "gone", "moved", "archived", "unused"},
+			expectedOK: true,
+		},
+		{
+			description: "it should fail to move an unknown rule",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.MoveBefore("unknown", "importers")
+			},
+			expected: []string{"importers", "gone", "moved", "archived", "unused", "fast-fork"},
+		},
+		{
+			description: "it should fail to move a rule before itself",
+			change: func(r *gddoexp.RuleSet) bool {
+				return r.MoveBefore("gone", "gone")
+			},
+			expected: []string{"importers", "gone", "moved", "archived", "unused", "fast-fork"},
+		},
+	}
+
+	for i, item := range data {
+		rules := gddoexp.NewDefaultRuleSet()
+
+		if ok := item.change(rules); ok != item.expectedOK {
+			t.Errorf("[%d] %s: expected change result to be %t", i, item.description, item.expectedOK)
+		}
+
+		var names []string
+		for _, rule := range rules.Rules() {
+			names = append(names, rule.Name())
+		}
+
+		if !reflect.DeepEqual(item.expected, names) {
+			t.Errorf("[%d] %s: expected rules “%v” and got “%v”", i, item.description, item.expected, names)
+		}
+	}
+}
+
+func TestRuleSetOrder(t *testing.T) {
+	var checked []string
+
+	rules := gddoexp.NewRuleSet(
+		ruleMock{name: "first", checked: &checked},
+		ruleMock{name: "second", checked: &checked, result: gddoexp.RuleResult{Matched: true, Suppress: true}},
+		ruleMock{name: "third", checked: &checked},
+	)
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = rules
+
+	suppress, cache, err := checker.ShouldSuppressPackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/gddoexp"}, nil)
+	if !suppress {
+		t.Error("expected package to be suppressed")
+	}
+
+	if !cache {
+		t.Error("expected hit in cache")
+	}
+
+	if err != nil {
+		t.Errorf("unexpected error “%v”", err)
+	}
+
+	if expected := []string{"first", "second"}; !reflect.DeepEqual(expected, checked) {
+		t.Errorf("expected rules “%v” to be checked and got “%v”", expected, checked)
+	}
+
+	verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/gddoexp"}, nil)
+	if err != nil {
+		t.Errorf("unexpected error “%v”", err)
+	}
+
+	expectedVerdict := gddoexp.Verdict{
+		Suppress: true,
+		Rule:     "second",
+		Checked:  []string{"first", "second"},
+		Skipped:  []string{"third"},
+	}
+
+	if !reflect.DeepEqual(expectedVerdict, verdict) {
+		t.Errorf("mismatch verdict.\n%v", diff(expectedVerdict, verdict))
+	}
+
+	checked = nil
+	rules.Add(ruleMock{name: "failure", checked: &checked, err: fmt.Errorf("i'm a crazy error")})
+	rules.MoveBefore("failure", "first")
+
+	_, _, err = checker.ShouldSuppressPackage(context.Background(), database.Package{Path: "github.com/rafaeljusto/gddoexp"}, nil)
+	if expected := fmt.Errorf("i'm a crazy error"); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected error to be “%v” and got “%v”", expected, err)
+	}
+
+	if expected := []string{"failure"}; !reflect.DeepEqual(expected, checked) {
+		t.Errorf("expected rules “%v” to be checked and got “%v”", expected, checked)
+	}
+}
+
+func TestGoneRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/deleted":
+			w.WriteHeader(http.StatusNotFound)
+		case "/repos/rafaeljusto/takedown":
+			w.WriteHeader(http.StatusUnavailableForLegalReasons)
+		case "/repos/rafaeljusto/renamed":
+			http.Redirect(w, r, "/repositories/42", http.StatusMovedPermanently)
+		case "/repositories/42":
+			fmt.Fprint(w, `{"id": 42, "full_name": "rafaeljusto/gddoexp"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.GoneRule{})
+
+	data := []struct {
+		description string
+		path        string
+		importers   int
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should suppress a deleted repository",
+			path:        "github.com/rafaeljusto/deleted",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "gone",
+				Reason:   "repository deleted",
+				Evidence: gddoexp.Evidence{"status": http.StatusNotFound, "importers": 0},
+				Checked:  []string{"gone"},
+			},
+		},
+		{
+			description: "it should suppress a repository unavailable for legal reasons",
+			path:        "github.com/rafaeljusto/takedown",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "gone",
+				Reason:   "repository unavailable for legal reasons",
+				Evidence: gddoexp.Evidence{"status": http.StatusUnavailableForLegalReasons, "importers": 0},
+				Checked:  []string{"gone"},
+			},
+		},
+		{
+			description: "it should keep a deleted repository that is still imported",
+			path:        "github.com/rafaeljusto/deleted",
+			importers:   3,
+			expected: gddoexp.Verdict{
+				Rule:     "gone",
+				Reason:   "repository deleted, but imported by 3 projects",
+				Evidence: gddoexp.Evidence{"status": http.StatusNotFound, "importers": 3},
+				Checked:  []string{"gone"},
+			},
+		},
+		{
+			description: "it should follow a renamed repository",
+			path:        "github.com/rafaeljusto/renamed",
+			expected: gddoexp.Verdict{
+				Checked: []string{"gone"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		db := databaseMock{
+			importerCountMock: func(path string) (int, error) {
+				return item.importers, nil
+			},
+		}
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+func TestMovedRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/oldname":
+			http.Redirect(w, r, "/repositories/42", http.StatusMovedPermanently)
+		case "/repositories/42", "/repos/rafaeljusto/gddoexp", "/repos/RafaelJusto/GDDOExp":
+			fmt.Fprint(w, `{"id": 42, "full_name": "rafaeljusto/gddoexp"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.MovedRule{})
+
+	data := []struct {
+		description string
+		path        string
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should detect a renamed repository",
+			path:        "github.com/rafaeljusto/oldname/cmd/gddoexp",
+			expected: gddoexp.Verdict{
+				Suppress:      true,
+				Rule:          "moved",
+				Reason:        "moved to github.com/rafaeljusto/gddoexp/cmd/gddoexp",
+				Evidence:      gddoexp.Evidence{"full_name": "rafaeljusto/gddoexp"},
+				CanonicalPath: "github.com/rafaeljusto/gddoexp/cmd/gddoexp",
+				Checked:       []string{"moved"},
+			},
+		},
+		{
+			description: "it should keep a package with the canonical path",
+			path:        "github.com/rafaeljusto/gddoexp",
+			expected: gddoexp.Verdict{
+				Checked: []string{"moved"},
+			},
+		},
+		{
+			description: "it should keep a package that only differs in the case",
+			path:        "github.com/RafaelJusto/GDDOExp",
+			expected: gddoexp.Verdict{
+				Checked: []string{"moved"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, nil)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+
+		if verdict.Moved() != (item.expected.CanonicalPath != "") {
+			t.Errorf("[%d] %s: unexpected moved flag", i, item.description)
+		}
+	}
+}
+
+func TestImportersRuleMoved(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/oldname":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/gddoexp", "pushed_at": "%s"}`, time.Now().Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+
+	// a single agent analyzes the packages in order, so the repository is
+	// already known when the imported package is checked
+	checker.Policy.Agents = 1
+
+	packages := []database.Package{
+		{Path: "github.com/rafaeljusto/oldname"},
+		{Path: "github.com/rafaeljusto/oldname/cmd/gddoexp"},
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			if path == "github.com/rafaeljusto/oldname/cmd/gddoexp" {
+				return 2, nil
+			}
+			return 0, nil
+		},
+	}
+
+	expected := map[string]gddoexp.Verdict{
+		"github.com/rafaeljusto/oldname": {
+			Suppress:      true,
+			Rule:          "moved",
+			Reason:        "moved to github.com/rafaeljusto/gddoexp",
+			Evidence:      gddoexp.Evidence{"full_name": "rafaeljusto/gddoexp"},
+			CanonicalPath: "github.com/rafaeljusto/gddoexp",
+			Checked:       []string{"importers", "gone", "moved"},
+			Skipped:       []string{"archived", "unused", "fast-fork"},
+		},
+		"github.com/rafaeljusto/oldname/cmd/gddoexp": {
+			Rule:          "importers",
+			Reason:        "imported by 2 projects, but moved to github.com/rafaeljusto/gddoexp/cmd/gddoexp",
+			Evidence:      gddoexp.Evidence{"importers": 2, "full_name": "rafaeljusto/gddoexp"},
+			CanonicalPath: "github.com/rafaeljusto/gddoexp/cmd/gddoexp",
+			Checked:       []string{"importers"},
+			Skipped:       []string{"gone", "moved", "archived", "unused", "fast-fork"},
+		},
+	}
+
+	for response := range checker.ShouldSuppressPackages(context.Background(), packages, db) {
+		if response.Error != nil {
+			t.Errorf("unexpected error “%v” for package “%s”", response.Error, response.Package.Path)
+			continue
+		}
+
+		// the repository URL depends on the test server address
+		verdict := response.Verdict
+		verdict.RepositoryURL = ""
+		if !reflect.DeepEqual(expected[response.Package.Path], verdict) {
+			t.Errorf("mismatch verdict for package “%s”.\n%v", response.Package.Path, diff(expected[response.Package.Path], verdict))
+		}
+	}
+}
+
+func TestArchivedRule(t *testing.T) {
+	old := time.Now().Add(-400 * 24 * time.Hour).UTC().Truncate(time.Second)
+	recent := time.Now().Add(-100 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/old":
+			fmt.Fprintf(w, `{"archived": true, "updated_at": "%s"}`, old.Format(time.RFC3339))
+		case "/repos/rafaeljusto/recent":
+			fmt.Fprintf(w, `{"archived": true, "updated_at": "%s"}`, recent.Format(time.RFC3339))
+		case "/repos/rafaeljusto/active":
+			fmt.Fprintf(w, `{"archived": false, "updated_at": "%s"}`, old.Format(time.RFC3339))
+		case "/repos/rafaeljusto/deleted":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.ArchivedRule{})
+
+	data := []struct {
+		description string
+		path        string
+		importers   int
+		weight      float64
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should suppress an archived repository",
+			path:        "github.com/rafaeljusto/old",
+			weight:      0.5,
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "archived",
+				Reason:   "archived and unused for 400 days",
+				Evidence: gddoexp.Evidence{
+					"importers":      0,
+					"updated_at":     old,
+					"idle_days":      400,
+					"threshold_days": 365,
+					"weight":         0.5,
+				},
+				Checked: []string{"archived"},
+			},
+		},
+		{
+			description: "it should keep an archived repository updated before the weighted period",
+			path:        "github.com/rafaeljusto/recent",
+			weight:      0.5,
+			expected: gddoexp.Verdict{
+				Checked: []string{"archived"},
+			},
+		},
+		{
+			description: "it should flag an imported archived repository as deprecated",
+			path:        "github.com/rafaeljusto/old",
+			importers:   2,
+			weight:      0.5,
+			expected: gddoexp.Verdict{
+				Rule:   "archived",
+				Reason: "archived, but imported by 2 projects",
+				Evidence: gddoexp.Evidence{
+					"importers":      2,
+					"updated_at":     old,
+					"idle_days":      400,
+					"threshold_days": 365,
+					"weight":         0.5,
+				},
+				Deprecated: true,
+				Checked:    []string{"archived"},
+			},
+		},
+		{
+			description: "it should keep a repository that isn't archived",
+			path:        "github.com/rafaeljusto/active",
+			weight:      0.5,
+			expected: gddoexp.Verdict{
+				Checked: []string{"archived"},
+			},
+		},
+		{
+			description: "it should leave a deleted repository to the other rules",
+			path:        "github.com/rafaeljusto/deleted",
+			weight:      0.5,
+			expected: gddoexp.Verdict{
+				Checked: []string{"archived"},
+			},
+		},
+		{
+			description: "it should be disabled with weight zero",
+			path:        "github.com/rafaeljusto/old",
+			expected: gddoexp.Verdict{
+				Checked: []string{"archived"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		checker.Policy.ArchivedWeight = item.weight
+
+		db := databaseMock{
+			importerCountMock: func(path string) (int, error) {
+				return item.importers, nil
+			},
+		}
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+func TestUnusedRuleGranularity(t *testing.T) {
+	recent := time.Now().Add(-10 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/gddoexp":
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/gddoexp", "updated_at": "%s"}`, recent.Format(time.RFC3339))
+
+		case "/repos/rafaeljusto/gddoexp/commits":
+			// the package directory is relative to the repository root
+			switch r.URL.Query().Get("path") {
+			case "cmd/dead":
+				fmt.Fprint(w, `[]`)
+			case "cmd/alive":
+				fmt.Fprintf(w, `[{"sha": "c1", "commit": {"author": {"date": "%s"}}}]`, recent.Format(time.RFC3339))
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.UnusedRule{})
+	checker.Policy.UnusedPeriod = 365 * 24 * time.Hour
+
+	data := []struct {
+		description string
+		path        string
+		granularity gddoexp.Granularity
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should keep a dead subpackage of an active repository",
+			path:        "github.com/rafaeljusto/gddoexp/cmd/dead",
+			granularity: gddoexp.GranularityRepository,
+			expected: gddoexp.Verdict{
+				Checked: []string{"unused"},
+			},
+		},
+		{
+			description: "it should suppress a dead subpackage with the package granularity",
+			path:        "github.com/rafaeljusto/gddoexp/cmd/dead",
+			granularity: gddoexp.GranularityPackage,
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "unused",
+				Reason:   "no commits in the package directory for 365 days",
+				Evidence: gddoexp.Evidence{
+					"activity":       "package_commit",
+					"granularity":    "package",
+					"commits":        0,
+					"threshold_days": 365,
+				},
+				Checked: []string{"unused"},
+			},
+		},
+		{
+			description: "it should keep an active subpackage with the package granularity",
+			path:        "github.com/rafaeljusto/gddoexp/cmd/alive",
+			granularity: gddoexp.GranularityPackage,
+			expected: gddoexp.Verdict{
+				Checked: []string{"unused"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		checker.Policy.Granularity = item.granularity
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, nil)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+func TestUnusedRuleActivity(t *testing.T) {
+	old := time.Now().Add(-400 * 24 * time.Hour).UTC().Truncate(time.Second)
+	recent := time.Now().Add(-10 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/rafaeljusto/starred":
+			// starring the repository changes the update date, but there's
+			// no push for a long time
+			fmt.Fprintf(w, `{"full_name": "rafaeljusto/starred", "updated_at": "%s", "pushed_at": "%s"}`,
+				recent.Format(time.RFC3339), old.Format(time.RFC3339))
+
+		case "/repos/rafaeljusto/starred/commits":
+			if r.URL.Query().Get("path") == "cmd/alive" {
+				fmt.Fprintf(w, `[{"sha": "c1", "commit": {"author": {"date": "%s"}}}]`, recent.Format(time.RFC3339))
+				return
+			}
+
+			fmt.Fprint(w, `[]`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.UnusedRule{})
+	checker.Policy.UnusedPeriod = 365 * 24 * time.Hour
+
+	data := []struct {
+		description string
+		path        string
+		activity    gddoexp.ActivitySignal
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should suppress a starred repository without pushes",
+			path:        "github.com/rafaeljusto/starred",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "unused",
+				Reason:   "unused for 400 days",
+				Evidence: gddoexp.Evidence{
+					"activity":       "pushed_at",
+					"pushed_at":      old,
+					"idle_days":      400,
+					"threshold_days": 365,
+				},
+				Checked: []string{"unused"},
+			},
+		},
+		{
+			description: "it should keep a starred repository using the update date",
+			path:        "github.com/rafaeljusto/starred",
+			activity:    gddoexp.ActivityUpdatedAt,
+			expected: gddoexp.Verdict{
+				Checked: []string{"unused"},
+			},
+		},
+		{
+			description: "it should suppress a repository without commits in the default branch",
+			path:        "github.com/rafaeljusto/starred",
+			activity:    gddoexp.ActivityLastCommit,
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "unused",
+				Reason:   "no commits in the default branch for 365 days",
+				Evidence: gddoexp.Evidence{
+					"activity":       "last_commit",
+					"commits":        0,
+					"threshold_days": 365,
+				},
+				Checked: []string{"unused"},
+			},
+		},
+		{
+			description: "it should keep a package with recent commits in its directory",
+			path:        "github.com/rafaeljusto/starred/cmd/alive",
+			activity:    gddoexp.ActivityPackageCommit,
+			expected: gddoexp.Verdict{
+				Checked: []string{"unused"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		checker.Policy.Activity = item.activity
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, nil)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+func TestFastForkRuleComparison(t *testing.T) {
+	forkDate := time.Now().Add(-400 * 24 * time.Hour)
+	recent := time.Now().Add(-10 * 24 * time.Hour)
+
+	var mutex sync.Mutex
+	branchRequests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/dated/dns":
+			// without the default branch the fork can't be compared
+			fmt.Fprintf(w, `{
+  "full_name": "dated/dns",
+  "fork": true,
+  "created_at": "%s",
+  "parent": {"full_name": "golang/dns", "default_branch": "main"}
+}`, forkDate.Format(time.RFC3339))
+
+		case r.URL.Path == "/repos/golang/dns":
+			fmt.Fprint(w, `{"full_name": "golang/dns", "default_branch": "main"}`)
+
+		case r.URL.Path == "/repos/dated/dns/commits":
+			fmt.Fprint(w, `[]`)
+
+		case strings.HasSuffix(r.URL.Path, "/dns") && !strings.HasPrefix(r.URL.Path, "/repos/golang/"):
+			// the fork date is old, so the commit dates can't be used
+			fmt.Fprintf(w, `{
+  "full_name": "%s",
+  "fork": true,
+  "default_branch": "master",
+  "created_at": "%s",
+  "parent": {"full_name": "golang/dns", "default_branch": "main"}
+}`, strings.TrimPrefix(r.URL.Path, "/repos/"), forkDate.Format(time.RFC3339))
+
+		case strings.HasPrefix(r.URL.Path, "/repos/golang/dns/compare/main..."):
+			// the head identifies the fork owner and branch
+			head := strings.TrimPrefix(r.URL.Path, "/repos/golang/dns/compare/main...")
+			aheadBy := map[string]int{
+				"merged": 0,
+				"closed": 2,
+				"open":   1,
+				"own":    1,
+				"big":    5,
+				"busy":   1,
+			}
+			fmt.Fprintf(w, `{"ahead_by": %d, "behind_by": 10}`, aheadBy[strings.TrimSuffix(head, ":master")])
+
+		case strings.HasSuffix(r.URL.Path, "/dns/branches"):
+			branches := map[string][]string{
+				"dated":  {"fix"},
+				"open":   {"master", "fix"},
+				"closed": {"feature", "master"},
+			}
+
+			fork := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/repos/"), "/dns/branches")
+			mutex.Lock()
+			branchRequests[fork]++
+			mutex.Unlock()
+
+			names, ok := branches[fork]
+			if !ok {
+				names = []string{"master"}
+			}
+
+			if fork == "busy" {
+				for i := 1; i <= 12; i++ {
+					names = append(names, fmt.Sprintf("feature-%d", i))
+				}
+			}
+
+			var list []string
+			for _, name := range names {
+				list = append(list, fmt.Sprintf(`{"name": "%s"}`, name))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(list, ","))
+
+		case r.URL.Path == "/repos/golang/dns/pulls":
+			if r.URL.Query().Get("state") != "all" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			// the pull requests are filtered by the head branch of the fork
+			pullRequests := map[string]string{
+				"dated:fix":      `{"number": 4, "state": "open"}`,
+				"open:fix":       `{"number": 3, "state": "open"}`,
+				"closed:master":  fmt.Sprintf(`{"number": 2, "state": "closed", "merged_at": "%s"}`, recent.Format(time.RFC3339)),
+				"closed:feature": `{"number": 1, "state": "closed"}`,
+			}
+
+			if pullRequest, ok := pullRequests[r.URL.Query().Get("head")]; ok {
+				fmt.Fprintf(w, "[%s]", pullRequest)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	data := []struct {
+		description string
+		fork        string
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should suppress a fork without commits ahead of the parent",
+			fork:        "merged",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "fast-fork",
+				Reason:   "fast fork without commits ahead of the parent",
+				Evidence: gddoexp.Evidence{
+					"commits":       0,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "golang/dns",
+					"ahead_by":      0,
+					"behind_by":     10,
+				},
+				Checked: []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should suppress a fork with all commits in merged or closed pull requests",
+			fork:        "closed",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "fast-fork",
+				Reason:   "fast fork with 2 commits ahead of the parent, all in merged or closed pull requests, disposable after its pull request was merged",
+				Evidence: gddoexp.Evidence{
+					"commits":       2,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "golang/dns",
+					"ahead_by":      2,
+					"behind_by":     10,
+					"pull_requests": 2,
+					"disposable":    true,
+				},
+				PullRequest: gddoexp.PullRequestMerged,
+				Checked:     []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork with an open pull request",
+			fork:        "open",
+			expected: gddoexp.Verdict{
+				Rule:   "fast-fork",
+				Reason: "fork with an open pull request, kept until it is resolved",
+				Evidence: gddoexp.Evidence{
+					"commits":       1,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "golang/dns",
+					"ahead_by":      1,
+					"behind_by":     10,
+					"pull_requests": 1,
+				},
+				PullRequest: gddoexp.PullRequestOpen,
+				Checked:     []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork with an open pull request using the commit dates",
+			fork:        "dated",
+			expected: gddoexp.Verdict{
+				Rule:   "fast-fork",
+				Reason: "fork with an open pull request, kept until it is resolved",
+				Evidence: gddoexp.Evidence{
+					"commits":       0,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "golang/dns",
+					"period_days":   7,
+					"pull_requests": 1,
+				},
+				PullRequest: gddoexp.PullRequestOpen,
+				Checked:     []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork with commits that weren't sent to the parent",
+			fork:        "own",
+			expected: gddoexp.Verdict{
+				Checked: []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork with too many commits ahead of the parent",
+			fork:        "big",
+			expected: gddoexp.Verdict{
+				Checked: []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork with too many branches to check its pull requests",
+			fork:        "busy",
+			expected: gddoexp.Verdict{
+				Rule:   "fast-fork",
+				Reason: "fork with too many branches to check its pull requests",
+				Evidence: gddoexp.Evidence{
+					"commits":       1,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "golang/dns",
+					"ahead_by":      1,
+					"behind_by":     10,
+				},
+				PullRequest: gddoexp.PullRequestUnknown,
+				Checked:     []string{"fast-fork"},
+			},
+		},
+	}
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.FastForkRule{})
+
+	for i, item := range data {
+		// each fork has a different owner, so the parent requests identify it
+		path := "github.com/" + item.fork + "/dns"
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: path}, nil)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+
+	// the pull requests of a fork with too many commits ahead don't change
+	// the verdict, so they aren't retrieved
+	mutex.Lock()
+	defer mutex.Unlock()
+	if branchRequests["big"] > 0 {
+		t.Errorf("unexpected pull requests check for a fork with too many commits ahead of the parent")
+	}
+}
+
+func TestFastForkRuleNetwork(t *testing.T) {
+	forkDate := time.Now().Add(-30 * 24 * time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/deep/pkg":
+			fmt.Fprintf(w, `{
+  "full_name": "deep/pkg",
+  "fork": true,
+  "created_at": "%s",
+  "parent": {"full_name": "middle/pkg"},
+  "source": {"full_name": "origin/pkg"}
+}`, forkDate.Format(time.RFC3339))
+
+		case "/repos/middle/pkg":
+			fmt.Fprint(w, `{"full_name": "middle/pkg", "fork": true, "parent": {"full_name": "origin/pkg"}}`)
+
+		case "/repos/origin/pkg":
+			fmt.Fprint(w, `{"full_name": "origin/pkg"}`)
+
+		case "/repos/orphan/pkg":
+			fmt.Fprintf(w, `{
+  "full_name": "orphan/pkg",
+  "fork": true,
+  "created_at": "%s",
+  "parent": {"full_name": "deleted/pkg"}
+}`, forkDate.Format(time.RFC3339))
+
+		case "/repos/deleted/pkg":
+			w.WriteHeader(http.StatusNotFound)
+
+		case "/repos/deep/pkg/commits", "/repos/orphan/pkg/commits", "/repos/deep/pkg/branches", "/repos/middle/pkg/pulls":
+			fmt.Fprint(w, `[]`)
+
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.FastForkRule{})
+
+	data := []struct {
+		description string
+		path        string
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should record the depth of a fork of a fork",
+			path:        "github.com/deep/pkg",
+			expected: gddoexp.Verdict{
+				Suppress: true,
+				Rule:     "fast-fork",
+				Reason:   "fast fork with 0 commits in 7 days after the fork",
+				Evidence: gddoexp.Evidence{
+					"commits":       0,
+					"commits_limit": 2,
+					"period_days":   7,
+					"fork_depth":    2,
+					"network_root":  "origin/pkg",
+				},
+				Checked: []string{"fast-fork"},
+			},
+		},
+		{
+			description: "it should keep a fork of a deleted repository",
+			path:        "github.com/orphan/pkg",
+			expected: gddoexp.Verdict{
+				Rule:   "fast-fork",
+				Reason: "fork of the deleted repository deleted/pkg, it may be the canonical copy now",
+				Evidence: gddoexp.Evidence{
+					"commits":       0,
+					"commits_limit": 2,
+					"fork_depth":    1,
+					"network_root":  "deleted/pkg",
+					"upstream_gone": true,
+				},
+				Checked: []string{"fast-fork"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, nil)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+func TestSuccessorRule(t *testing.T) {
+	old := time.Now().Add(-3 * 365 * 24 * time.Hour).UTC().Truncate(time.Second)
+	recent := time.Now().Add(-10 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/successor/pkg":
+			fmt.Fprintf(w, `{"full_name": "successor/pkg", "fork": true, "pushed_at": "%s", "stargazers_count": 50, "parent": {"full_name": "original/pkg"}}`,
+				recent.Format(time.RFC3339))
+		case "/repos/hobby/pkg":
+			fmt.Fprintf(w, `{"full_name": "hobby/pkg", "fork": true, "pushed_at": "%s", "parent": {"full_name": "original/pkg"}}`,
+				recent.Format(time.RFC3339))
+		case "/repos/copy/pkg":
+			fmt.Fprintf(w, `{"full_name": "copy/pkg", "fork": true, "pushed_at": "%s", "stargazers_count": 50, "parent": {"full_name": "active/pkg"}}`,
+				recent.Format(time.RFC3339))
+		case "/repos/original/pkg":
+			fmt.Fprintf(w, `{"full_name": "original/pkg", "pushed_at": "%s", "stargazers_count": 10}`, old.Format(time.RFC3339))
+		case "/repos/active/pkg":
+			fmt.Fprintf(w, `{"full_name": "active/pkg", "pushed_at": "%s", "stargazers_count": 10}`, recent.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.SuccessorRule{})
+	checker.Policy.UnusedPeriod = 365 * 24 * time.Hour
+
+	importers := map[string]int{
+		"github.com/successor/pkg/sub":  3,
+		"github.com/original/pkg/sub":   5,
+		"gopkg.in/successor/pkg.v1/sub": 3,
+	}
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			return importers[path], nil
+		},
+	}
+
+	data := []struct {
+		description string
+		path        string
+		expected    gddoexp.Verdict
+	}{
+		{
+			description: "it should detect a fork that supersedes a dead parent",
+			path:        "github.com/successor/pkg/sub",
+			expected: gddoexp.Verdict{
+				Rule:   "successor",
+				Reason: "fork successor/pkg supersedes parent original/pkg",
+				Evidence: gddoexp.Evidence{
+					"active_at":        recent,
+					"parent_active_at": old,
+					"stars":            50,
+					"parent_stars":     10,
+					"importers":        3,
+					"parent_importers": 5,
+					"threshold_days":   365,
+				},
+				Supersedes: "github.com/original/pkg/sub",
+				Checked:    []string{"successor"},
+			},
+		},
+		{
+			description: "it should compare only the stars of a gopkg.in fork",
+			path:        "gopkg.in/successor/pkg.v1/sub",
+			expected: gddoexp.Verdict{
+				Rule:   "successor",
+				Reason: "fork successor/pkg supersedes parent original/pkg",
+				Evidence: gddoexp.Evidence{
+					"active_at":        recent,
+					"parent_active_at": old,
+					"stars":            50,
+					"parent_stars":     10,
+					"importers":        3,
+					"threshold_days":   365,
+				},
+				RepositoryURL: "https://github.com/successor/pkg",
+				Supersedes:    "github.com/original/pkg/sub",
+				Checked:       []string{"successor"},
+			},
+		},
+		{
+			description: "it should ignore an active fork less popular than the parent",
+			path:        "github.com/hobby/pkg/sub",
+			expected: gddoexp.Verdict{
+				Checked: []string{"successor"},
+			},
+		},
+		{
+			description: "it should ignore a fork of an active parent",
+			path:        "github.com/copy/pkg/sub",
+			expected: gddoexp.Verdict{
+				Checked: []string{"successor"},
+			},
+		},
+		{
+			description: "it should ignore a repository that isn't a fork",
+			path:        "github.com/original/pkg/sub",
+			expected: gddoexp.Verdict{
+				Checked: []string{"successor"},
+			},
+		},
+	}
+
+	for i, item := range data {
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+		if err != nil {
+			t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(item.expected, verdict) {
+			t.Errorf("[%d] %s: mismatch verdict.\n%v", i, item.description, diff(item.expected, verdict))
+		}
+	}
+}
+
+type ruleMock struct {
+	name    string
+	checked *[]string
+	result  gddoexp.RuleResult
+	err     error
+}
+
+func (r ruleMock) Name() string {
+	return r.name
+}
+
+func (r ruleMock) Check(s *gddoexp.Subject) (gddoexp.RuleResult, error) {
+	if r.checked != nil {
+		*r.checked = append(*r.checked, r.name)
+	}
+
+	return r.result, r.err
+}
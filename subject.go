@@ -0,0 +1,573 @@
+package gddoexp
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+// Subject stores the package under analysis. The information from GoDoc
+// database and from the hosting service is only retrieved when a rule asks
+// for it, and it's kept for the next rules, so the same request isn't sent
+// twice.
+type Subject struct {
+	Package database.Package
+
+	// Policy stores the thresholds that the rules should use.
+	Policy Policy
+
+	// resolved is where the package information is retrieved from, that is
+	// different from the package path for gopkg.in and vanity import paths.
+	// Vanity import paths are only resolved when a rule needs the repository,
+	// as the go-import meta tags must be retrieved.
+	resolved       resolvedPath
+	resolvedErr    error
+	resolvedLoaded bool
+
+	ctx     context.Context
+	checker *Checker
+	db      gddoDB
+	cache   bool
+
+	// memo shares the responses with the other packages of the same run. It
+	// is nil when the package is analyzed alone.
+	memo *fetchMemo
+
+	// shared is the number of responses retrieved by other packages of the
+	// run that were used by this package.
+	shared int
+
+	importerCount       int
+	importerCountLoaded bool
+
+	doc       *doc.Package
+	docErr    error
+	docLoaded bool
+
+	repository       *Repository
+	repositoryErr    error
+	repositoryLoaded bool
+
+	// repositoryRoot is the path of the repository root found when the
+	// repository was retrieved.
+	repositoryRoot string
+
+	// ref is the branch or tag resolved from the gopkg.in version selector.
+	ref       string
+	refErr    error
+	refLoaded bool
+
+	// commits stores the commits already retrieved, indexed by the path used
+	// to filter them.
+	commits map[string]commitsResult
+
+	comparison       *Comparison
+	comparisonErr    error
+	comparisonLoaded bool
+
+	pullRequests       []PullRequest
+	pullRequestsErr    error
+	pullRequestsLoaded bool
+
+	network       *ForkNetwork
+	networkErr    error
+	networkLoaded bool
+
+	parent       *Repository
+	parentErr    error
+	parentLoaded bool
+}
+
+// maxForkDepth limits the parents visited when walking a fork network, so a
+// broken chain of parents doesn't send requests forever.
+const maxForkDepth = 10
+
+// commitsResult stores the commits retrieved for a path.
+type commitsResult struct {
+	commits []Commit
+	err     error
+}
+
+// newSubject builds a subject for the package that uses the checker policy and
+// HTTP clients. The database is only necessary when a rule needs the
+// importer counter. The context is used in all requests sent for the package.
+func (c *Checker) newSubject(ctx context.Context, p database.Package, db gddoDB) *Subject {
+	return &Subject{
+		Package: p,
+		Policy:  c.Policy,
+		resolved: resolvedPath{
+			path: p.Path,
+		},
+		resolvedLoaded: true,
+		ctx:            ctx,
+		checker:        c,
+		db:             db,
+		cache:          true,
+	}
+}
+
+// Context returns the context of the evaluation. Rules that send their own
+// requests should use it, so the evaluation can be cancelled.
+func (s *Subject) Context() context.Context {
+	return s.ctx
+}
+
+// RepositoryPath returns the package path in its hosting service (e.g.
+// github.com/owner/repo/pkg). It is the package path itself, unless the
+// package uses a gopkg.in or vanity import path. It is empty when the vanity
+// import path can't be resolved.
+func (s *Subject) RepositoryPath() string {
+	s.resolve()
+	return s.resolved.path
+}
+
+// resolve maps the vanity import path to the repository that backs it, if it
+// wasn't resolved yet.
+func (s *Subject) resolve() error {
+	if s.resolvedLoaded {
+		return s.resolvedErr
+	}
+
+	resolved, cache, err := s.checker.resolveVanity(s.ctx, s.Package.Path)
+	s.resolved = resolved
+	s.resolvedErr = err
+	s.resolvedLoaded = true
+	s.cache = s.cache && cache
+	return err
+}
+
+// ImporterCount returns the number of projects from GoDoc database that
+// import the package.
+func (s *Subject) ImporterCount() (int, error) {
+	if s.importerCountLoaded {
+		return s.importerCount, nil
+	}
+
+	count, err := s.db.ImporterCount(s.Package.Path)
+	if err != nil {
+		return 0, NewError(s.Package.Path, ErrorCodeRetrieveImportCounts, err)
+	}
+
+	s.importerCount = count
+	s.importerCountLoaded = true
+	return count, nil
+}
+
+// Doc returns the documentation of the package stored in GoDoc database. It
+// returns nil when the database doesn't store the documentation or when the
+// package wasn't found.
+func (s *Subject) Doc() (*doc.Package, error) {
+	if s.docLoaded {
+		return s.doc, s.docErr
+	}
+
+	if db, ok := s.db.(docDB); ok {
+		pdoc, _, err := db.GetDoc(s.Package.Path)
+		if err != nil {
+			s.docErr = NewError(s.Package.Path, ErrorCodeRetrieveDoc, err)
+		} else {
+			s.doc = pdoc
+		}
+	}
+
+	s.docLoaded = true
+	return s.doc, s.docErr
+}
+
+// Repository returns the repository information of the package from its
+// hosting service. When the hosting service has nested groups, the possible
+// repository roots are checked in order, and the repository is only reported
+// as not found when none of them exist.
+func (s *Subject) Repository() (*Repository, error) {
+	if s.repositoryLoaded {
+		return s.repository, s.repositoryErr
+	}
+
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+
+	roots := []string{repositoryRoot(s.resolved.path)}
+	if nestedProvider, ok := s.checker.Providers.Lookup(s.resolved.path).(NestedProvider); ok {
+		roots = nestedProvider.Roots(s.resolved.path)
+	}
+
+	for _, root := range roots {
+		s.repositoryRoot = root
+		s.fetchRepository(root)
+
+		// a path that doesn't exist can be a group with the project inside
+		if goneStatus(s.repositoryErr) != http.StatusNotFound {
+			break
+		}
+	}
+
+	s.repositoryLoaded = true
+	return s.repository, s.repositoryErr
+}
+
+// knownRepository returns the repository information only when it was already
+// retrieved, by this package or by another package of the run (like the
+// repositories retrieved in advance with GraphQL), so no request is sent. It
+// returns nil otherwise.
+func (s *Subject) knownRepository() *Repository {
+	if s.repositoryLoaded {
+		return s.repository
+	}
+
+	if s.memo == nil {
+		return nil
+	}
+
+	// vanity import paths are only resolved with a request
+	resolved, ok, err := s.checker.resolve(s.Package.Path)
+	if !ok || err != nil {
+		return nil
+	}
+
+	// the root of nested groups is only known after the request
+	if _, ok := s.checker.Providers.Lookup(resolved.path).(NestedProvider); ok {
+		return nil
+	}
+
+	call, ok := s.memo.peek(repositoryKey(repositoryRoot(resolved.path)))
+	if !ok {
+		return nil
+	}
+
+	repository, _ := call.value.(*Repository)
+	return repository
+}
+
+// fetchRepository retrieves the repository by its root, so the response can
+// be shared by all packages of the repository.
+func (s *Subject) fetchRepository(root string) {
+	value, err := s.fetch(repositoryKey(root), func() (interface{}, bool, error) {
+		return s.checker.repository(s.ctx, root)
+	})
+	s.repository, _ = value.(*Repository)
+	s.repositoryErr = err
+}
+
+// root returns the path of the repository root of the package. For hosting
+// services with nested groups the root is only known after the repository is
+// retrieved.
+func (s *Subject) root() (string, error) {
+	if err := s.resolve(); err != nil {
+		return "", err
+	}
+
+	if _, ok := s.checker.Providers.Lookup(s.resolved.path).(NestedProvider); !ok {
+		return repositoryRoot(s.resolved.path), nil
+	}
+
+	if _, err := s.Repository(); err != nil {
+		return "", err
+	}
+
+	return s.repositoryRoot, nil
+}
+
+// Commits returns the commits of the package repository made in the policy
+// unused period. With the package granularity only the commits that touched
+// the package directory are returned. For gopkg.in import paths only the
+// commits of the branch or tag with the highest version matching the selector
+// are returned.
+func (s *Subject) Commits() ([]Commit, error) {
+	if s.Policy.Granularity == GranularityPackage {
+		return s.PackageCommits()
+	}
+
+	root, err := s.root()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commitsIn(root)
+}
+
+// PackageCommits returns the commits that touched the package directory in
+// the policy unused period, whatever the policy granularity.
+func (s *Subject) PackageCommits() ([]Commit, error) {
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+
+	return s.commitsIn(s.resolved.path)
+}
+
+// commitsIn returns the commits made in the policy unused period that touched
+// the directory of the path.
+func (s *Subject) commitsIn(path string) ([]Commit, error) {
+	if result, ok := s.commits[path]; ok {
+		return result.commits, result.err
+	}
+
+	root, err := s.root()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := s.resolveRef()
+	if err != nil {
+		return nil, err
+	}
+
+	var result commitsResult
+	since := time.Now().Add(-s.Policy.UnusedPeriod)
+	value, err := s.fetch(commitsKey(path, ref, s.Policy.UnusedPeriod), func() (interface{}, bool, error) {
+		return s.checker.commits(s.ctx, root, path, ref, since)
+	})
+	result.commits, _ = value.([]Commit)
+	result.err = err
+
+	if s.commits == nil {
+		s.commits = make(map[string]commitsResult)
+	}
+	s.commits[path] = result
+	return result.commits, result.err
+}
+
+// resolveRef returns the branch or tag of the package, that is empty for the
+// default branch. The gopkg.in version selector is resolved to the branch or
+// tag served by gopkg.in, sharing the response with the other packages of the
+// repository.
+func (s *Subject) resolveRef() (string, error) {
+	if !s.resolved.version {
+		return s.resolved.ref, nil
+	}
+
+	if s.refLoaded {
+		return s.ref, s.refErr
+	}
+
+	root := repositoryRoot(s.resolved.path)
+	value, err := s.fetch(refKey(root, s.resolved.ref), func() (interface{}, bool, error) {
+		return s.checker.gopkginRef(s.ctx, root, s.resolved.ref)
+	})
+	s.ref, _ = value.(string)
+	s.refErr = err
+	s.refLoaded = true
+	return s.ref, s.refErr
+}
+
+// Comparison returns the comparison of the package repository with its parent
+// repository. It returns nil when the repository isn't a fork, when its
+// hosting service can't compare repositories or when the parent doesn't exist
+// anymore.
+func (s *Subject) Comparison() (*Comparison, error) {
+	if s.comparisonLoaded {
+		return s.comparison, s.comparisonErr
+	}
+
+	value, err := s.forkFetch(comparisonKey, func(provider ForkProvider, repository *Repository, ref string) (interface{}, bool, error) {
+		return provider.CompareWithParent(s.ctx, repository, ref)
+	})
+	s.comparison, _ = value.(*Comparison)
+	s.comparisonErr = err
+	s.comparisonLoaded = true
+	return s.comparison, s.comparisonErr
+}
+
+// PullRequests returns the pull requests sent from any branch of the package
+// repository to its parent repository. It returns nil in the same cases of
+// Comparison.
+func (s *Subject) PullRequests() ([]PullRequest, error) {
+	if s.pullRequestsLoaded {
+		return s.pullRequests, s.pullRequestsErr
+	}
+
+	value, err := s.forkFetch(pullRequestsKey, func(provider ForkProvider, repository *Repository, ref string) (interface{}, bool, error) {
+		return provider.PullRequests(s.ctx, repository, ref)
+	})
+	s.pullRequests, _ = value.([]PullRequest)
+	s.pullRequestsErr = err
+	s.pullRequestsLoaded = true
+	return s.pullRequests, s.pullRequestsErr
+}
+
+// forkFetch retrieves information that relates the fork with its parent
+// repository, sharing the response with the other packages of the run by the
+// key built from the repository root and the ref of the package. A parent that
+// doesn't exist anymore isn't an error, so the rules can use other
+// information.
+func (s *Subject) forkFetch(key func(root, ref string) string, fetch func(ForkProvider, *Repository, string) (interface{}, bool, error)) (interface{}, error) {
+	repository, err := s.Repository()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := s.root()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, ok := s.checker.Providers.Lookup(s.resolved.path).(ForkProvider)
+	if !ok || !repository.Fork || repository.Parent == "" {
+		return nil, nil
+	}
+
+	ref, err := s.resolveRef()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.fetch(key(root, ref), func() (interface{}, bool, error) {
+		return fetch(provider, repository, ref)
+	})
+	if goneStatus(err) != 0 {
+		return nil, nil
+	}
+
+	return value, err
+}
+
+// Network returns the position of the package repository in its fork
+// network, walking the parents up to the root repository. It returns nil when
+// the repository isn't a fork.
+func (s *Subject) Network() (*ForkNetwork, error) {
+	if s.networkLoaded {
+		return s.network, s.networkErr
+	}
+
+	s.network, s.networkErr = s.walkNetwork()
+	s.networkLoaded = true
+	return s.network, s.networkErr
+}
+
+// walkNetwork visits the parents of the fork until it finds a repository that
+// isn't a fork or that doesn't exist anymore.
+func (s *Subject) walkNetwork() (*ForkNetwork, error) {
+	repository, err := s.Repository()
+	if err != nil {
+		return nil, err
+	}
+
+	if !repository.Fork {
+		return nil, nil
+	}
+
+	network := &ForkNetwork{
+		Root:  repository.Parent,
+		Depth: 1,
+	}
+
+	if network.Root == "" {
+		network.Root = repository.Source
+	}
+
+	current := repository
+	for current.Parent != "" {
+		if network.Depth > maxForkDepth {
+			// too many parents, so we trust the root reported by the hosting
+			// service
+			if repository.Source != "" {
+				network.Root = repository.Source
+			}
+			break
+		}
+
+		parent, err := s.upstream(current.Parent)
+		if goneStatus(err) != 0 {
+			network.Root = current.Parent
+			network.UpstreamGone = true
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		network.Root = parent.FullName
+
+		if !parent.Fork {
+			break
+		}
+
+		network.Depth++
+		current = parent
+	}
+
+	return network, nil
+}
+
+// Parent returns the repository information of the parent of the package
+// repository. It returns nil when the repository isn't a fork, when the parent
+// is unknown or when the parent doesn't exist anymore.
+func (s *Subject) Parent() (*Repository, error) {
+	if s.parentLoaded {
+		return s.parent, s.parentErr
+	}
+
+	repository, err := s.Repository()
+	if err == nil && repository.Fork && repository.Parent != "" {
+		s.parent, err = s.upstream(repository.Parent)
+		if goneStatus(err) != 0 {
+			err = nil
+		}
+	}
+
+	s.parentErr = err
+	s.parentLoaded = true
+	return s.parent, s.parentErr
+}
+
+// upstream retrieves the repository information of another repository of the
+// fork network, identified by its full name. The repositories of a fork
+// network are in the same hosting service.
+func (s *Subject) upstream(fullName string) (*Repository, error) {
+	path := host(s.resolved.path) + "/" + fullName
+	value, err := s.fetch(repositoryKey(path), func() (interface{}, bool, error) {
+		return s.checker.repository(s.ctx, path)
+	})
+
+	repository, _ := value.(*Repository)
+	return repository, err
+}
+
+// fetch retrieves a response, sharing it with the other packages of the run
+// when there's a memo. A shared response isn't a cache hit by itself: it keeps
+// the cache status of the request that retrieved it.
+func (s *Subject) fetch(key string, fetch func() (interface{}, bool, error)) (interface{}, error) {
+	var value interface{}
+	var cache bool
+	var err error
+
+	if s.memo == nil {
+		value, cache, err = fetch()
+	} else {
+		call, shared := s.memo.do(key, fetch)
+		value, cache, err = call.value, call.cache, call.err
+		if shared {
+			s.shared++
+		}
+	}
+
+	s.cache = s.cache && cache
+	return value, err
+}
+
+// sharedRoot returns the root of the repository retrieved for the package in
+// a run with other packages, or an empty string when the repository wasn't
+// retrieved.
+func (s *Subject) sharedRoot() string {
+	if s.memo == nil || !s.repositoryLoaded {
+		return ""
+	}
+
+	return s.repositoryRoot
+}
+
+// Cache returns true when all the requests performed so far were
+// retrieved from the local cache. If no request was sent it's also considered
+// a cache hit.
+func (s *Subject) Cache() bool {
+	return s.cache
+}
+
+// Shared returns the number of responses retrieved by other packages of the
+// same run that were used by this package, instead of sending the requests.
+func (s *Subject) Shared() int {
+	return s.shared
+}
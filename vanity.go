@@ -0,0 +1,233 @@
+package gddoexp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// vanityRoot is a repository root declared in a go-import meta tag, like
+// <meta name="go-import" content="example.com/pkg git https://github.com/owner/pkg">.
+type vanityRoot struct {
+	// prefix is the import path that corresponds to the repository root.
+	prefix string
+
+	// vcs is the version control system of the repository (e.g. git).
+	vcs string
+
+	// repoURL is the location of the repository.
+	repoURL string
+}
+
+// vanityResolver maps vanity import paths to the repositories that back them,
+// following the go-import meta tags as the go tool does. The repository roots
+// are cached per prefix, so the subpackages of a vanity path don't send new
+// requests.
+type vanityResolver struct {
+	checker *Checker
+
+	mutex sync.RWMutex
+	roots map[string]vanityRoot
+
+	// unresolved stores the paths that answered without a go-import meta tag,
+	// so a host that doesn't serve them isn't queried again for the same
+	// path.
+	unresolved map[string]bool
+}
+
+// newVanityResolver builds a resolver that sends the requests using the
+// checker HTTP client, without the Github credentials.
+func newVanityResolver(checker *Checker) *vanityResolver {
+	return &vanityResolver{
+		checker:    checker,
+		roots:      make(map[string]vanityRoot),
+		unresolved: make(map[string]bool),
+	}
+}
+
+// cached returns the repository root of the path when the path or one of its
+// parents was already resolved.
+func (v *vanityResolver) cached(path string) (vanityRoot, bool, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	if v.unresolved[path] {
+		return vanityRoot{}, false, true
+	}
+
+	for prefix := path; ; {
+		if root, ok := v.roots[prefix]; ok {
+			return root, true, true
+		}
+
+		i := strings.LastIndex(prefix, "/")
+		if i == -1 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+
+	return vanityRoot{}, false, false
+}
+
+// lookup retrieves the repository root of the path from the go-import meta
+// tags. When the path doesn't declare a repository root false is returned.
+// This function also returns if the response was retrieved from a local
+// cache.
+func (v *vanityResolver) lookup(ctx context.Context, path string) (vanityRoot, bool, bool, error) {
+	if root, found, ok := v.cached(path); ok {
+		return root, found, true, nil
+	}
+
+	request, err := http.NewRequest("GET", "https://"+path+"?go-get=1", nil)
+	if err != nil {
+		return vanityRoot{}, false, false, NewError(path, ErrorCodeVanityFetch, err)
+	}
+
+	response, err := v.checker.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return vanityRoot{}, false, false, ctx.Err()
+		}
+
+		// the URL is already identified by the path, so we only keep the
+		// transport problem
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+
+		return vanityRoot{}, false, false, NewError(path, ErrorCodeVanityFetch, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return vanityRoot{}, false, false, NewError(path, ErrorCodeVanityFetch,
+			fmt.Errorf("status code %d", response.StatusCode))
+	}
+	cache := v.checker.isCacheResponse(response)
+
+	roots, err := parseMetaGoImports(response.Body)
+	if err != nil {
+		return vanityRoot{}, false, false, NewError(path, ErrorCodeVanityParse, err)
+	}
+
+	root, found, err := matchVanityRoot(path, roots)
+	if err != nil {
+		return vanityRoot{}, false, false, NewError(path, ErrorCodeVanityParse, err)
+	}
+
+	v.mutex.Lock()
+	if found {
+		v.roots[root.prefix] = root
+	} else {
+		v.unresolved[path] = true
+	}
+	v.mutex.Unlock()
+
+	return root, found, cache, nil
+}
+
+// parseMetaGoImports returns the repository roots declared in the go-import
+// meta tags of a HTML page. As the go tool, it only reads the page head and
+// tolerates malformed HTML.
+func parseMetaGoImports(r io.Reader) ([]vanityRoot, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// the meta tags are ASCII, so any charset compatible with it works
+		return input, nil
+	}
+
+	var roots []vanityRoot
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			if err == io.EOF || len(roots) > 0 {
+				return roots, nil
+			}
+
+			return nil, err
+		}
+
+		if e, ok := token.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return roots, nil
+		}
+
+		if e, ok := token.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return roots, nil
+		}
+
+		e, ok := token.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+
+		if f := strings.Fields(attrValue(e.Attr, "content")); len(f) == 3 {
+			roots = append(roots, vanityRoot{
+				prefix:  f[0],
+				vcs:     f[1],
+				repoURL: f[2],
+			})
+		}
+	}
+}
+
+// attrValue returns the value of the HTML attribute with the given name.
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// matchVanityRoot selects the repository root that contains the path. The
+// module proxy entries ("mod") don't identify a repository, so they are
+// ignored.
+func matchVanityRoot(path string, roots []vanityRoot) (vanityRoot, bool, error) {
+	var match vanityRoot
+	var found bool
+
+	for _, root := range roots {
+		if root.vcs == "mod" {
+			continue
+		}
+
+		if path != root.prefix && !strings.HasPrefix(path, root.prefix+"/") {
+			continue
+		}
+
+		if found && match != root {
+			return vanityRoot{}, false, fmt.Errorf("multiple go-import meta tags match “%s”", path)
+		}
+
+		match, found = root, true
+	}
+
+	return match, found, nil
+}
+
+// repositoryPath converts the repository URL of a go-import meta tag into the
+// import path of the repository (e.g. https://github.com/owner/repo.git is
+// github.com/owner/repo).
+func repositoryPath(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Host == "" {
+		return "", fmt.Errorf("repository URL without host")
+	}
+
+	return u.Hostname() + strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git"), nil
+}
@@ -0,0 +1,165 @@
+package gddoexp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/rafaeljusto/gddoexp"
+)
+
+func TestVanityImportPath(t *testing.T) {
+	var metaRequests int
+
+	httpClient := httpClientMock{
+		getMock: func(url string) (*http.Response, error) {
+			if strings.HasSuffix(url, "?go-get=1") {
+				metaRequests++
+			}
+
+			switch url {
+			case "https://example.com/yaml?go-get=1", "https://example.com/yaml/encoding?go-get=1":
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: ioutil.NopCloser(bytes.NewBufferString(`<!DOCTYPE html>
+<html>
+<head>
+<meta name="go-import" content="example.com/yaml mod https://proxy.example.com">
+<meta name="go-import" content="example.com/yaml git https://github.com/rafaeljusto/yaml.git">
+</head>
+<body>
+<meta name="go-import" content="example.com/yaml git https://github.com/rafaeljusto/other">
+</body>
+</html>`)),
+				}, nil
+
+			case "https://example.com/svn?go-get=1":
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`<html><head><meta name="go-import" content="example.com/svn svn https://svn.example.com/repo"></head></html>`)),
+				}, nil
+
+			case "https://example.com/plain?go-get=1":
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`<html><head><title>plain</title></head></html>`)),
+				}, nil
+
+			case "https://api.github.com/repos/rafaeljusto/yaml":
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: ioutil.NopCloser(bytes.NewBufferString(`{
+  "full_name": "rafaeljusto/yaml",
+  "created_at": "2010-08-03T21:56:23Z",
+  "updated_at": "` + time.Now().Add(-3*365*24*time.Hour).Format(time.RFC3339) + `"
+}`)),
+				}, nil
+			}
+
+			return &http.Response{StatusCode: http.StatusNotFound}, nil
+		},
+	}
+
+	checker, err := gddoexp.NewChecker(gddoexp.Config{
+		HTTPClient:    &http.Client{Transport: httpClient},
+		ResolveVanity: true,
+	})
+	if err != nil {
+		t.Fatalf("error building checker: %s", err)
+	}
+	checker.Rules = gddoexp.NewRuleSet(gddoexp.ImportersRule{}, gddoexp.MovedRule{}, gddoexp.UnusedRule{})
+
+	db := databaseMock{
+		importerCountMock: func(path string) (int, error) {
+			if path == "example.com/yaml/popular" {
+				return 5, nil
+			}
+			return 0, nil
+		},
+	}
+
+	data := []struct {
+		description      string
+		path             string
+		expected         bool
+		expectedRule     string
+		expectedURL      string
+		expectedRequests int
+		expectedError    error
+	}{
+		{
+			description:      "it should suppress a package from a vanity import path",
+			path:             "example.com/yaml",
+			expected:         true,
+			expectedRule:     "unused",
+			expectedURL:      "https://github.com/rafaeljusto/yaml.git",
+			expectedRequests: 1,
+		},
+		{
+			description:  "it should reuse the repository root for a subpackage",
+			path:         "example.com/yaml/encoding",
+			expected:     true,
+			expectedRule: "unused",
+			expectedURL:  "https://github.com/rafaeljusto/yaml.git",
+		},
+		{
+			description:  "it should keep a package with importers without resolving the vanity import path",
+			path:         "example.com/yaml/popular",
+			expectedRule: "importers",
+		},
+		{
+			description:      "it should fail when the repository isn't from a supported hosting service",
+			path:             "example.com/svn",
+			expectedRequests: 1,
+			expectedError:    gddoexp.ErrorCodeUnsupportedHost,
+		},
+		{
+			description:      "it should fail when there's no go-import meta tag",
+			path:             "example.com/plain",
+			expectedRequests: 1,
+			expectedError:    gddoexp.ErrorCodeUnsupportedHost,
+		},
+		{
+			description:      "it should fail when the go-import meta tags can't be retrieved",
+			path:             "example.com/missing",
+			expectedRequests: 1,
+			expectedError:    gddoexp.ErrorCodeVanityFetch,
+		},
+	}
+
+	for i, item := range data {
+		metaRequests = 0
+
+		verdict, _, err := checker.EvaluatePackage(context.Background(), database.Package{Path: item.path}, db)
+
+		if item.expectedError == nil {
+			if err != nil {
+				t.Errorf("[%d] %s: unexpected error “%v”", i, item.description, err)
+			}
+		} else if !errors.Is(err, item.expectedError) {
+			t.Errorf("[%d] %s: expected error “%v” and got “%v”", i, item.description, item.expectedError, err)
+		}
+
+		if verdict.Suppress != item.expected {
+			t.Errorf("[%d] %s: expected suppress to be %t", i, item.description, item.expected)
+		}
+
+		if verdict.Rule != item.expectedRule {
+			t.Errorf("[%d] %s: expected rule “%s” and got “%s”", i, item.description, item.expectedRule, verdict.Rule)
+		}
+
+		if verdict.RepositoryURL != item.expectedURL {
+			t.Errorf("[%d] %s: expected repository URL “%s” and got “%s”", i, item.description, item.expectedURL, verdict.RepositoryURL)
+		}
+
+		if metaRequests != item.expectedRequests {
+			t.Errorf("[%d] %s: expected %d go-import requests and got %d", i, item.description, item.expectedRequests, metaRequests)
+		}
+	}
+}
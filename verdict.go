@@ -0,0 +1,67 @@
+package gddoexp
+
+// Evidence stores the values used by a rule to take its decision, indexed by
+// name. The values are simple types, so the evidence can be stored as JSON.
+type Evidence map[string]interface{}
+
+// Verdict explains the suppression decision of a package, so a reviewer can
+// judge it before changing the GoDoc database.
+type Verdict struct {
+	// Suppress is true when the package should be suppressed.
+	Suppress bool `json:"suppress"`
+
+	// Rule is the name of the rule that took the decision. It is empty when
+	// no rule matched.
+	Rule string `json:"rule,omitempty"`
+
+	// Reason describes in a human readable message why the rule matched.
+	Reason string `json:"reason,omitempty"`
+
+	// Evidence stores the values used by the rule that took the decision.
+	Evidence Evidence `json:"evidence,omitempty"`
+
+	// CanonicalPath is the import path of the package in the new location of
+	// a renamed or transferred repository. GoDoc can redirect to it instead of
+	// indexing a duplicate.
+	CanonicalPath string `json:"canonical_path,omitempty"`
+
+	// RepositoryURL is the repository that backs a vanity import path, as
+	// declared in its go-import meta tag. It is empty for packages that are
+	// analyzed by their own path, or when no rule needed the repository.
+	RepositoryURL string `json:"repository_url,omitempty"`
+
+	// Deprecated is true when the package is kept because other projects
+	// import it, but its repository was archived by the owner.
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// PullRequest is the state of the pull requests sent from a fork to its
+	// parent repository: open while any of them is open, unknown when some
+	// branches of the fork couldn't be checked, merged when any of them was
+	// merged, and closed otherwise. It is empty when the fork didn't send pull
+	// requests or they weren't checked.
+	PullRequest PullRequestState `json:"pull_request,omitempty"`
+
+	// Supersedes is the import path of the parent package when the package is
+	// a fork that became the maintained successor of a dead parent. GoDoc can
+	// rank the fork higher and suppress the parent. For gopkg.in and vanity
+	// import paths it is the parent path in its hosting service.
+	Supersedes string `json:"supersedes,omitempty"`
+
+	// DuplicateOf is the import path of the canonical package when the
+	// package is a mirror or a vendored copy of it. GoDoc can redirect to the
+	// canonical package instead of indexing the copy.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+
+	// Checked lists the rules that were checked, in order.
+	Checked []string `json:"checked,omitempty"`
+
+	// Skipped lists the rules that weren't checked because a previous rule
+	// already took the decision.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Moved returns true when the package path points to a repository that was
+// renamed or transferred.
+func (v Verdict) Moved() bool {
+	return v.CanonicalPath != ""
+}